@@ -31,12 +31,7 @@ type Decoder struct {
 	dec *marshaler.Decoder
 }
 
-func NewDecoder(cli *capi.Client, opts ...DecoderOption) (*Decoder, error) {
-	kv := &consulKV{ckv: cli.KV()}
-	var cfg decoderConfig
-	for _, opt := range opts {
-		opt(&cfg)
-	}
+func (cfg *decoderConfig) options() []marshaler.DecoderOption {
 	decOpts := make([]marshaler.DecoderOption, 0)
 	decOpts = append(decOpts, marshaler.WithSeparator("/"))
 	decOpts = append(decOpts, marshaler.WithTag("consul"))
@@ -46,7 +41,16 @@ func NewDecoder(cli *capi.Client, opts ...DecoderOption) (*Decoder, error) {
 	if cfg.prefix != "" {
 		decOpts = append(decOpts, marshaler.WithPrefix(cfg.prefix))
 	}
-	dec, err := marshaler.NewDecoder(kv, decOpts...)
+	return decOpts
+}
+
+func NewDecoder(cli *capi.Client, opts ...DecoderOption) (*Decoder, error) {
+	kv := &consulKV{ckv: cli.KV()}
+	var cfg decoderConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	dec, err := marshaler.NewDecoder(kv, cfg.options()...)
 	if err != nil {
 		return nil, fmt.Errorf("create decoder: %w", err)
 	}
@@ -61,6 +65,12 @@ func (d *Decoder) DecodeContext(ctx context.Context, v any) error {
 	return d.dec.DecodeContext(ctx, v)
 }
 
+// Watch decodes a fresh value on each change of keys in Consul KV,
+// see [marshaler.Decoder.Watch] for details.
+func (d *Decoder) Watch(ctx context.Context, newFn func() any, onChange func(v any, err error)) error {
+	return d.dec.Watch(ctx, newFn, onChange)
+}
+
 func Unmarshal(cli *capi.Client, v any) error {
 	return UnmarshalContext(context.Background(), cli, v)
 }
@@ -0,0 +1,48 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/g4s8/go-marshaler"
+	capi "github.com/hashicorp/consul/api"
+)
+
+type Encoder struct {
+	enc *marshaler.Encoder
+}
+
+// NewEncoder creates an encoder that writes struct values to consul KV.
+// It accepts the same options as NewDecoder.
+func NewEncoder(cli *capi.Client, opts ...DecoderOption) (*Encoder, error) {
+	kv := &consulKV{ckv: cli.KV()}
+	var cfg decoderConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	enc, err := marshaler.NewEncoder(kv, cfg.options()...)
+	if err != nil {
+		return nil, fmt.Errorf("create encoder: %w", err)
+	}
+	return &Encoder{enc: enc}, nil
+}
+
+func (e *Encoder) Encode(v any) error {
+	return e.enc.Encode(v)
+}
+
+func (e *Encoder) EncodeContext(ctx context.Context, v any) error {
+	return e.enc.EncodeContext(ctx, v)
+}
+
+func Marshal(cli *capi.Client, v any) error {
+	return MarshalContext(context.Background(), cli, v)
+}
+
+func MarshalContext(ctx context.Context, cli *capi.Client, v any) error {
+	enc, err := NewEncoder(cli)
+	if err != nil {
+		return fmt.Errorf("create encoder: %w", err)
+	}
+	return enc.EncodeContext(ctx, v)
+}
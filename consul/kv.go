@@ -3,12 +3,23 @@ package consul
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/g4s8/go-marshaler"
 	capi "github.com/hashicorp/consul/api"
 )
 
-var _ marshaler.KV = (*consulKV)(nil)
+var (
+	_ marshaler.KV            = (*consulKV)(nil)
+	_ marshaler.Lister        = (*consulKV)(nil)
+	_ marshaler.PrefixFetcher = (*consulKV)(nil)
+	_ marshaler.KVWriter      = (*consulKV)(nil)
+	_ marshaler.Watcher       = (*consulKV)(nil)
+)
+
+// watchRetry is a delay before retrying a failed blocking query.
+const watchRetry = time.Second
 
 type consulKV struct {
 	ckv *capi.KV
@@ -31,3 +42,84 @@ func (kv *consulKV) Get(ctx context.Context, key string) (marshaler.Value, error
 
 	return marshaler.NewBytesValue(pair.Value), nil
 }
+
+func (kv *consulKV) List(ctx context.Context, prefix string) ([]string, error) {
+	keys, _, err := kv.ckv.Keys(prefix, "", (&capi.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("list keys %q: %w", prefix, err)
+	}
+	return keys, nil
+}
+
+func (kv *consulKV) FetchPrefix(ctx context.Context, prefix string) (map[string]marshaler.Value, error) {
+	pairs, _, err := kv.ckv.List(prefix, (&capi.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("list prefix %q: %w", prefix, err)
+	}
+	values := make(map[string]marshaler.Value, len(pairs))
+	for _, pair := range pairs {
+		if strings.HasSuffix(pair.Key, "/") {
+			continue // skip folders
+		}
+		values[pair.Key] = marshaler.NewBytesValue(pair.Value)
+	}
+	return values, nil
+}
+
+func (kv *consulKV) Put(ctx context.Context, key string, value string) error {
+	pair := &capi.KVPair{Key: key, Value: []byte(value)}
+	if _, err := kv.ckv.Put(pair, (&capi.WriteOptions{}).WithContext(ctx)); err != nil {
+		return fmt.Errorf("put key %q: %w", key, err)
+	}
+	return nil
+}
+
+func (kv *consulKV) Delete(ctx context.Context, key string) error {
+	if _, err := kv.ckv.Delete(key, (&capi.WriteOptions{}).WithContext(ctx)); err != nil {
+		return fmt.Errorf("delete key %q: %w", key, err)
+	}
+	return nil
+}
+
+// Watch notifies about changes under the prefix using blocking queries.
+// Failed queries are retried after a delay until ctx is done.
+func (kv *consulKV) Watch(ctx context.Context, prefix string) (<-chan struct{}, error) {
+	_, meta, err := kv.ckv.List(prefix, (&capi.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("list prefix %q: %w", prefix, err)
+	}
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		index := meta.LastIndex
+		for {
+			opts := (&capi.QueryOptions{WaitIndex: index}).WithContext(ctx)
+			_, meta, err := kv.ckv.List(prefix, opts)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(watchRetry):
+				}
+				continue
+			}
+			if meta.LastIndex == index {
+				continue // wait timeout
+			}
+			if meta.LastIndex < index {
+				// the index went backwards, e.g. after snapshot restore.
+				index = 0
+			} else {
+				index = meta.LastIndex
+			}
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return changes, nil
+}
@@ -0,0 +1,153 @@
+package consul
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/g4s8/go-marshaler"
+	capi "github.com/hashicorp/consul/api"
+)
+
+// testPrefix is a prefix of keys written by tests.
+const testPrefix = "go-marshaler-test/"
+
+// newTestKV returns consul KV of TEST_CONSUL_ADDR agent,
+// keys under testPrefix are deleted after the test.
+func newTestKV(t *testing.T) *consulKV {
+	t.Helper()
+	consulAddr := os.Getenv("TEST_CONSUL_ADDR")
+	if consulAddr == "" {
+		t.Skip("TEST_CONSUL_ADDR is not set")
+	}
+	cli, err := capi.NewClient(&capi.Config{Address: consulAddr})
+	if err != nil {
+		t.Fatalf("error creating consul client: %v", err)
+	}
+	kv := &consulKV{ckv: cli.KV()}
+	t.Cleanup(func() {
+		if _, err := kv.ckv.DeleteTree(testPrefix, nil); err != nil {
+			t.Logf("error deleting prefix %q: %v", testPrefix, err)
+		}
+	})
+	return kv
+}
+
+func TestKV(t *testing.T) {
+	kv := newTestKV(t)
+	ctx := context.Background()
+
+	for key, value := range map[string]string{
+		testPrefix + "host":         "localhost",
+		testPrefix + "logger/level": "info",
+	} {
+		if err := kv.Put(ctx, key, value); err != nil {
+			t.Fatalf("error putting key %q: %v", key, err)
+		}
+	}
+	// folder placeholder, e.g. created by the Consul UI.
+	if _, err := kv.ckv.Put(&capi.KVPair{Key: testPrefix + "folder/"}, nil); err != nil {
+		t.Fatalf("error putting folder: %v", err)
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		val, err := kv.Get(ctx, testPrefix+"host")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if s, ok := val.(marshaler.StringValue); !ok || s.String() != "localhost" {
+			t.Fatalf("expected %q, got %v", "localhost", val)
+		}
+		val, err = kv.Get(ctx, testPrefix+"missing")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if val != marshaler.NullValue {
+			t.Fatalf("expected null value, got %v", val)
+		}
+	})
+	t.Run("List", func(t *testing.T) {
+		keys, err := kv.List(ctx, testPrefix)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := []string{testPrefix + "folder/", testPrefix + "host", testPrefix + "logger/level"}
+		if !slices.Equal(keys, expected) {
+			t.Fatalf("expected %v, got %v", expected, keys)
+		}
+	})
+	t.Run("FetchPrefix", func(t *testing.T) {
+		values, err := kv.FetchPrefix(ctx, testPrefix+"logger/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(values) != 1 {
+			t.Fatalf("expected single value, got %v", values)
+		}
+		if s, ok := values[testPrefix+"logger/level"].(marshaler.StringValue); !ok || s.String() != "info" {
+			t.Fatalf("unexpected values: %v", values)
+		}
+		values, err = kv.FetchPrefix(ctx, testPrefix)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := values[testPrefix+"folder/"]; ok || len(values) != 2 {
+			t.Fatalf("unexpected values: %v", values)
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		if err := kv.Delete(ctx, testPrefix+"host"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := kv.Delete(ctx, testPrefix+"host"); err != nil {
+			t.Fatalf("unexpected error of missing key: %v", err)
+		}
+		val, err := kv.Get(ctx, testPrefix+"host")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if val != marshaler.NullValue {
+			t.Fatalf("expected null value, got %v", val)
+		}
+	})
+}
+
+func TestKVWatch(t *testing.T) {
+	kv := newTestKV(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := kv.Put(ctx, testPrefix+"port", "80"); err != nil {
+		t.Fatalf("error putting key: %v", err)
+	}
+	changes, err := kv.Watch(ctx, testPrefix)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := kv.Put(ctx, testPrefix+"port", "81"); err != nil {
+		t.Fatalf("error putting key: %v", err)
+	}
+	select {
+	case _, ok := <-changes:
+		if !ok {
+			t.Fatalf("changes channel is closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("change was not delivered")
+	}
+
+	cancel()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("watch was not stopped")
+		}
+	}
+}
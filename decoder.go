@@ -5,12 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var defaultConfig = decoderConfig{
-	separator: "/",
-	sliceSep:  ",",
-	tag:       "kv",
+	separator:   "/",
+	sliceSep:    ",",
+	tag:         "kv",
+	concurrency: 1,
+	debounce:    100 * time.Millisecond,
 }
 
 var (
@@ -18,6 +23,13 @@ var (
 	ErrEmptyKeySep   = fmt.Errorf("empty key separator")
 	ErrEmptySliceSep = fmt.Errorf("empty slice separator")
 	ErrorEmptyTag    = fmt.Errorf("empty tag name")
+	ErrNilKeyFunc    = fmt.Errorf("nil key function")
+	ErrConcurrency   = fmt.Errorf("concurrency must be positive")
+	ErrNilStrategy   = fmt.Errorf("nil name strategy")
+	ErrNilHandler    = fmt.Errorf("nil unknown keys handler")
+	ErrEmptyScheme   = fmt.Errorf("empty resolver scheme")
+	ErrNilResolver   = fmt.Errorf("nil resolver")
+	ErrDebounce      = fmt.Errorf("negative debounce duration")
 )
 
 type decoderConfig struct {
@@ -25,6 +37,22 @@ type decoderConfig struct {
 	sliceSep  string
 	tag       string
 	prefix    string
+	keyFunc   KeyFunc
+
+	nameStrategy NameStrategy
+
+	requireAll    bool
+	collectErrors bool
+	redactValues  bool
+	concurrency   int
+
+	strict        bool
+	unknownKeysFn func(keys []string)
+
+	interpolate bool
+	resolvers   map[string]Resolver
+
+	debounce time.Duration
 }
 
 // DecoderOption is an option for decoder configuration.
@@ -72,6 +100,8 @@ func WithTag(tag string) DecoderOption {
 }
 
 // WithPrefix sets the prefix for all keys.
+//
+// The prefix is joined with keys by the key separator.
 func WithPrefix(prefix string) DecoderOption {
 	return func(d *decoderConfig) error {
 		d.prefix = prefix
@@ -79,15 +109,174 @@ func WithPrefix(prefix string) DecoderOption {
 	}
 }
 
-// Decoder reads and decodes values from a key-value storage.
-type Decoder struct {
-	kv     KV
-	config decoderConfig
+// WithKeyFunc sets a custom function to build keys from key paths,
+// e.g. to build `LOGGER_LEVEL` style keys:
+//
+//	marshaler.WithKeyFunc(func(path []string) string {
+//		return strings.ToUpper(strings.Join(path, "_"))
+//	})
+//
+// The key separator should match the function to list nested keys
+// of map and indexed slice fields.
+//
+// Default is joining the path by the key separator.
+func WithKeyFunc(fn KeyFunc) DecoderOption {
+	return func(d *decoderConfig) error {
+		if fn == nil {
+			return ErrNilKeyFunc
+		}
+		d.keyFunc = fn
+		return nil
+	}
 }
 
-// NewDecoder returns a new decoder that reads from kv.
-func NewDecoder(kv KV, opts ...DecoderOption) (*Decoder, error) {
-	dec := &Decoder{kv: kv}
+// WithNameStrategy sets the strategy to derive keys of fields
+// without tags from field names, e.g. [SnakeCase] or custom function.
+//
+// Fields with `-` tag are skipped. By default, fields without tags are skipped.
+func WithNameStrategy(names NameStrategy) DecoderOption {
+	return func(d *decoderConfig) error {
+		if names == nil {
+			return ErrNilStrategy
+		}
+		d.nameStrategy = names
+		return nil
+	}
+}
+
+// WithRequireAll makes all fields required, except fields with
+// `omitempty` tag option or default value.
+//
+// See [MissingKeysError] for more details.
+func WithRequireAll() DecoderOption {
+	return func(d *decoderConfig) error {
+		d.requireAll = true
+		return nil
+	}
+}
+
+// WithCollectErrors makes the decoder continue decoding after field errors
+// and return all of them joined by errors.Join.
+//
+// Each error could be extracted by errors.As with [*DecodeError] target.
+func WithCollectErrors() DecoderOption {
+	return func(d *decoderConfig) error {
+		d.collectErrors = true
+		return nil
+	}
+}
+
+// WithRedactedValues hides raw values from all decode errors.
+//
+// Values of fields with `secret` tag option are always hidden.
+func WithRedactedValues() DecoderOption {
+	return func(d *decoderConfig) error {
+		d.redactValues = true
+		return nil
+	}
+}
+
+// WithConcurrency sets the maximum number of parallel requests
+// to the key-value storage.
+//
+// Values of struct fields are fetched in parallel before decoding,
+// then they are decoded in field order. Map and slice elements are
+// fetched sequentially while decoding. It's not used if the values
+// are prefetched, see [PrefixFetcher].
+//
+// Default is 1, which means sequential fetching.
+func WithConcurrency(n int) DecoderOption {
+	return func(d *decoderConfig) error {
+		if n < 1 {
+			return ErrConcurrency
+		}
+		d.concurrency = n
+		return nil
+	}
+}
+
+// WithStrict makes the decoder report keys under the prefix
+// which were not consumed by any field, e.g. misspelled keys.
+//
+// Unknown keys are returned as [*UnknownKeysError] after decoding.
+// The key-value storage must implement [Lister].
+func WithStrict() DecoderOption {
+	return func(d *decoderConfig) error {
+		d.strict = true
+		return nil
+	}
+}
+
+// WithUnknownKeysHandler enables strict mode like [WithStrict],
+// but unknown keys are passed to fn instead of failing the decoding,
+// e.g. to log warnings. The fn is not called if there are no unknown keys.
+func WithUnknownKeysHandler(fn func(keys []string)) DecoderOption {
+	return func(d *decoderConfig) error {
+		if fn == nil {
+			return ErrNilHandler
+		}
+		d.strict = true
+		d.unknownKeysFn = fn
+		return nil
+	}
+}
+
+// WithInterpolation enables expanding of references in string values:
+//   - `${logger/level}` is replaced with the value of the key, the key path
+//     is relative to the prefix and uses the key separator;
+//   - `${env:HOME}` is replaced with the environment variable;
+//   - `$${` is an escaped `${`.
+//
+// Referenced values are expanded recursively, reference cycles and missing
+// references are reported as [*DecodeError] with [ErrInterpolation] kind.
+// Default values are expanded as well.
+func WithInterpolation() DecoderOption {
+	return func(d *decoderConfig) error {
+		d.interpolate = true
+		return nil
+	}
+}
+
+// WithResolver registers the resolver of values with the URI scheme,
+// e.g. `file` for `file:///run/secrets/db_pass` values.
+//
+// String values starting with `scheme:` are resolved before unmarshaling,
+// after interpolation if it's enabled. See [Resolver] for more details
+// and [FileResolver], [EnvResolver], [Base64Resolver] for built-in resolvers.
+func WithResolver(scheme string, r Resolver) DecoderOption {
+	return func(d *decoderConfig) error {
+		if scheme == "" {
+			return ErrEmptyScheme
+		}
+		if r == nil {
+			return ErrNilResolver
+		}
+		resolvers := make(map[string]Resolver, len(d.resolvers)+1)
+		for k, v := range d.resolvers {
+			resolvers[k] = v
+		}
+		resolvers[scheme] = r
+		d.resolvers = resolvers
+		return nil
+	}
+}
+
+// WithDebounce sets the period to wait for more changes
+// before decoding again in [Decoder.Watch], so bursts of updates
+// are coalesced into one decoding.
+//
+// Default is 100ms, zero means decoding right after the change.
+func WithDebounce(d time.Duration) DecoderOption {
+	return func(c *decoderConfig) error {
+		if d < 0 {
+			return ErrDebounce
+		}
+		c.debounce = d
+		return nil
+	}
+}
+
+func newDecoderConfig(opts []DecoderOption) (decoderConfig, error) {
 	cfg := defaultConfig
 
 	var errs []error
@@ -97,11 +286,92 @@ func NewDecoder(kv KV, opts ...DecoderOption) (*Decoder, error) {
 		}
 	}
 	if len(errs) > 0 {
-		return nil, errors.Join(errs...)
+		return decoderConfig{}, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// valueOpts returns value unmarshaling options for the field.
+func (c *decoderConfig) valueOpts(spec tagSpec) ValueUnmarshalOpts {
+	opts := ValueUnmarshalOpts{SliceSep: c.sliceSep}
+	if spec.sep != "" {
+		opts.SliceSep = spec.sep
+	}
+	return opts
+}
+
+// isRequired checks if the field must have a value.
+func (c *decoderConfig) isRequired(spec tagSpec) bool {
+	if spec.hasDef {
+		return false
+	}
+	return spec.required || (c.requireAll && !spec.omitempty)
+}
+
+// Decoder reads and decodes values from a key-value storage.
+type Decoder struct {
+	kv     KV
+	config decoderConfig
+	keys   keyBuilder
+	plans  *planCache
+
+	// state is set only for the copy of decoder
+	// used by single decode call.
+	state *decodeState
+}
+
+// decodeState holds the state of a single decode call.
+type decodeState struct {
+	// missing is a list of missing required keys.
+	missing []string
+	// errs is a list of collected errors, see WithCollectErrors.
+	errs []error
+	// consumed is a set of keys read by fields, it's tracked
+	// only in strict mode, see WithStrict.
+	consumed map[string]struct{}
+}
+
+// location is a location of decoded value.
+type location struct {
+	// path is a key path and key is the full key of the path.
+	path []string
+	key  string
+	// field is a Go field path.
+	field string
+	// secret is true if values should be redacted from errors.
+	secret bool
+}
+
+// at returns location of the planned field relative to l.
+func (l location) at(keys keyBuilder, fp *fieldPlan) location {
+	if len(l.path) == 0 {
+		// plan paths are relative to the decode target.
+		return location{path: fp.path, key: fp.key, field: fp.field, secret: fp.secret}
+	}
+	path := make([]string, 0, len(l.path)+len(fp.path))
+	path = append(append(path, l.path...), fp.path...)
+	return location{
+		path:   path,
+		key:    keys.key(path),
+		field:  l.field + "." + fp.field,
+		secret: l.secret || fp.secret,
 	}
+}
+
+// elem returns location of map, slice or array element.
+func (l location) elem(keys keyBuilder, segment, index string) location {
+	path := appendPath(l.path, segment)
+	return location{path: path, key: keys.key(path), field: l.field + "[" + index + "]", secret: l.secret}
+}
 
-	dec.config = cfg
-	return dec, nil
+// NewDecoder returns a new decoder that reads from kv.
+func NewDecoder(kv KV, opts ...DecoderOption) (*Decoder, error) {
+	cfg, err := newDecoderConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	keys := newKeyBuilder(cfg)
+	return &Decoder{kv: kv, config: cfg, keys: keys, plans: newPlanCache(cfg, keys)}, nil
 }
 
 // Decode reads values from the key-value storage and decodes them into v.
@@ -111,6 +381,15 @@ func (d *Decoder) Decode(v any) error {
 
 // DecodeContext reads values from the key-value storage and decodes them into v.
 // It uses the provided context for the deadline and cancellation.
+//
+// Field errors are returned as [*DecodeError], missing required keys
+// are returned as [*MissingKeysError] and unknown keys in strict mode
+// are returned as [*UnknownKeysError]. If the decoder is configured with
+// [WithCollectErrors], all errors are joined by errors.Join.
+//
+// If the key-value storage implements [PrefixFetcher] and the decoder
+// has a prefix, all values under the prefix are fetched at once before
+// decoding. Without a prefix it would fetch the whole storage.
 func (d *Decoder) DecodeContext(ctx context.Context, v any) error {
 	val := reflect.ValueOf(v)
 	if val.Kind() != reflect.Ptr || val.IsNil() {
@@ -121,53 +400,235 @@ func (d *Decoder) DecodeContext(ctx context.Context, v any) error {
 		return fmt.Errorf("decode target must be a pointer to a struct")
 	}
 
-	return d.decodeStruct(ctx, d.config.prefix, val)
+	dec := d.withState()
+	if fetcher, ok := d.kv.(PrefixFetcher); ok && d.keys.prefix != "" {
+		values, err := fetcher.FetchPrefix(ctx, d.keys.prefix)
+		if err != nil {
+			return fmt.Errorf("%w: fetch prefix %q: %w", ErrBackend, d.keys.prefix, err)
+		}
+		dec.kv = snapshotKV(values)
+	}
+	plan, err := d.plans.get(val.Type())
+	if err != nil {
+		return err
+	}
+	if _, ok := dec.kv.(snapshotKV); !ok && d.config.concurrency > 1 {
+		dec.kv = fetchConcurrently(ctx, d.kv, d.keys, plan.fields, d.config.concurrency)
+	}
+	if err := dec.decodeStruct(ctx, location{}, plan.fields, val); err != nil {
+		return err
+	}
+	errs := dec.state.errs
+	if len(dec.state.missing) > 0 {
+		errs = append(errs, &MissingKeysError{Keys: dec.state.missing})
+	}
+	if d.config.strict {
+		if err := dec.checkUnknown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// withState returns a copy of decoder with a new decode state.
+func (d *Decoder) withState() *Decoder {
+	dec := *d
+	dec.state = new(decodeState)
+	if d.config.strict {
+		dec.state.consumed = make(map[string]struct{})
+	}
+	return &dec
 }
 
-func (d *Decoder) decodeStruct(ctx context.Context, prefix string, val reflect.Value) error {
-	t := val.Type()
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Field(i)
-		if !field.CanSet() || !field.CanAddr() {
-			continue // Skip unexported and unaddressable fields
-		}
+// consume records the key read by a field in strict mode.
+func (d *Decoder) consume(key string) {
+	if d.state.consumed != nil {
+		d.state.consumed[key] = struct{}{}
+	}
+}
 
-		fieldType := t.Field(i)
-		if err := d.decodeField(ctx, field, fieldType, prefix); err != nil {
-			return fmt.Errorf("decode field %s: %w", fieldType.Name, err)
+// checkUnknown lists keys under the prefix and reports keys which
+// were not consumed by the decoding, see WithStrict.
+func (d *Decoder) checkUnknown(ctx context.Context) error {
+	keys, ok, err := d.list(ctx, d.keys.prefix)
+	if err != nil {
+		return fmt.Errorf("%w: strict mode: %w", ErrBackend, err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: strict mode: kv doesn't support listing keys", ErrBackend)
+	}
+	var unknown []string
+	for _, key := range keys {
+		if strings.HasSuffix(key, d.keys.sep) {
+			continue // directory placeholder, e.g. `folder/` in Consul
+		}
+		if !d.isConsumed(key) {
+			unknown = append(unknown, key)
 		}
 	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	if d.config.unknownKeysFn != nil {
+		d.config.unknownKeysFn(unknown)
+		return nil
+	}
+	return &UnknownKeysError{Keys: unknown}
+}
 
-	return nil
+// isConsumed checks if the key was consumed. Joined and indexed
+// layouts of a slice may overlap, e.g. `ports` and `ports/0`,
+// so reading either of them consumes both.
+func (d *Decoder) isConsumed(key string) bool {
+	consumed := d.state.consumed
+	if _, ok := consumed[key]; ok {
+		return true
+	}
+	if _, ok := consumed[key+d.keys.sep+"0"]; ok {
+		return true
+	}
+	i := strings.LastIndex(key, d.keys.sep)
+	if i < 0 {
+		return false
+	}
+	if _, err := strconv.ParseUint(key[i+len(d.keys.sep):], 10, 0); err != nil {
+		return false
+	}
+	_, ok := consumed[key[:i]]
+	return ok
+}
+
+// fail returns the field error, or collects it and returns nil
+// if the decoder collects errors and the context is not done.
+func (d *Decoder) fail(ctx context.Context, err *DecodeError) error {
+	if d.config.collectErrors && ctx.Err() == nil {
+		d.state.errs = append(d.state.errs, err)
+		return nil
+	}
+	return err
+}
+
+// missing records missing required key.
+func (d *Decoder) missing(loc location) {
+	d.state.missing = append(d.state.missing, loc.key)
+}
+
+// newError creates a field error at the location.
+func (d *Decoder) newError(loc location, t reflect.Type, kind, err error) *DecodeError {
+	return &DecodeError{
+		Field: loc.field,
+		Key:   loc.key,
+		Type:  t,
+		Kind:  kind,
+		Err:   err,
+	}
+}
+
+// valueError creates a field error of unmarshaling the value.
+func (d *Decoder) valueError(loc location, t reflect.Type, value Value, err error) *DecodeError {
+	kind := ErrParse
+	if errors.Is(err, ErrUnsupportedType) {
+		kind = ErrUnsupportedType
+	}
+	return d.kindError(loc, t, value, kind, err)
+}
+
+// kindError creates a field error of the kind with the value,
+// the value is hidden if it should be redacted.
+func (d *Decoder) kindError(loc location, t reflect.Type, value Value, kind, err error) *DecodeError {
+	derr := d.newError(loc, t, kind, err)
+	if loc.secret || d.config.redactValues {
+		derr.Redacted = true
+	} else if s, ok := value.(fmt.Stringer); ok {
+		derr.Value = s.String()
+	}
+	return derr
 }
 
-func (d *Decoder) decodeField(ctx context.Context, f reflect.Value, t reflect.StructField, prefix string) error {
-	tag := t.Tag.Get(d.config.tag)
-	if tag == "" {
-		return nil // Skip fields without consul tag
+// prepareValue interpolates and resolves the value before unmarshaling.
+//
+// Resolved values are usually secrets, so the location is marked
+// as secret to redact them from errors.
+func (d *Decoder) prepareValue(ctx context.Context, value Value, loc *location, t reflect.Type) (Value, *DecodeError) {
+	expanded, err := d.interpolate(ctx, value, loc.key)
+	if err != nil {
+		return nil, d.kindError(*loc, t, value, ErrInterpolation, err)
+	}
+	resolved, ok, err := d.resolve(ctx, expanded)
+	if err != nil {
+		return nil, d.kindError(*loc, t, expanded, ErrResolve, err)
+	}
+	if ok {
+		loc.secret = true
+	}
+	return resolved, nil
+}
+
+// decodeStruct decodes struct fields by plan relative to the base location.
+func (d *Decoder) decodeStruct(ctx context.Context, base location, fields []fieldPlan, val reflect.Value) error {
+	for i := range fields {
+		if err := d.decodeField(ctx, val.Field(fields[i].index), &fields[i], base); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	tagSpec := getTagSpec(tag)
+func (d *Decoder) decodeField(ctx context.Context, f reflect.Value, fp *fieldPlan, base location) error {
+	loc := base.at(d.keys, fp)
 
-	key := prefix + tagSpec.key
+	if fp.kind == structFieldKind && fp.required {
+		found, err := d.structExists(ctx, base, loc, fp)
+		if err != nil {
+			return d.fail(ctx, d.newError(loc, fp.typ, ErrBackend, err))
+		}
+		if !found {
+			d.missing(loc)
+			return nil
+		}
+	}
 
-	initField(f, t)
+	// If the field is a pointer and is nil, create a new instance
+	if fp.typ.Kind() == reflect.Ptr && f.IsNil() {
+		f.Set(reflect.New(fp.typ.Elem()))
+	}
 
-	// check if the field is a struct or a pointer to a struct.
-	// if so, recursively decode the struct.
-	if sf, ok := structField(f, t); ok {
-		if err := d.decodeStruct(ctx, key+"/", sf); err != nil {
-			return fmt.Errorf("decode struct field %s: %w", t.Name, err)
+	switch fp.kind {
+	case structFieldKind:
+		if f.Kind() == reflect.Ptr {
+			f = f.Elem()
 		}
-		return nil
+		if fp.recursive {
+			plan, err := d.plans.get(f.Type())
+			if err != nil {
+				return err
+			}
+			return d.decodeStruct(ctx, loc, plan.fields, f)
+		}
+		return d.decodeStruct(ctx, base, fp.fields, f)
+	case mapField:
+		return d.decodeMap(ctx, f, loc, fp)
+	case indexedField:
+		return d.decodeIndexed(ctx, f, loc, fp)
 	}
 
-	value, err := d.kv.Get(ctx, key)
+	d.consume(loc.key)
+	value, err := d.kv.Get(ctx, loc.key)
 	if err != nil {
-		return fmt.Errorf("get key %q: %w", key, err)
+		return d.fail(ctx, d.newError(loc, fp.typ, ErrBackend, err))
+	}
+	if value == NullValue && fp.spec.hasDef {
+		value = NewStringValue(fp.spec.def)
+	}
+	if value == NullValue && fp.required {
+		d.missing(loc)
+		return nil
+	}
+	value, derr := d.prepareValue(ctx, value, &loc, fp.typ)
+	if derr != nil {
+		return d.fail(ctx, derr)
 	}
 
-	opts := ValueUnmarshalOpts{SliceSep: d.config.sliceSep}
 	var out any
 	if f.Kind() == reflect.Ptr {
 		out = f.Interface()
@@ -175,10 +636,325 @@ func (d *Decoder) decodeField(ctx context.Context, f reflect.Value, t reflect.St
 		out = f.Addr().Interface()
 	}
 
-	if err := value.UnmarshalTo(out, opts); err != nil {
-		return fmt.Errorf("unmarshal value of %q: %w", key, err)
+	if err := value.UnmarshalTo(out, fp.opts); err != nil {
+		return d.fail(ctx, d.valueError(loc, fp.typ, value, err))
+	}
+
+	return nil
+}
+
+// decodeMap decodes map field with string keys. The map keys are
+// the names of direct children of the field key, listed by [Lister].
+// If the map value is a struct, each entry is decoded as nested struct,
+// otherwise each entry is decoded as a single value.
+func (d *Decoder) decodeMap(ctx context.Context, f reflect.Value, loc location, fp *fieldPlan) error {
+	t := f.Type()
+	if t.Key().Kind() != reflect.String {
+		err := fmt.Errorf("%w: map key type %s", ErrUnsupportedType, t.Key())
+		return d.fail(ctx, d.newError(loc, t, ErrUnsupportedType, err))
+	}
+	prefix := d.keys.listPrefix(loc.path)
+	keys, ok, err := d.list(ctx, prefix)
+	if err != nil {
+		return d.fail(ctx, d.newError(loc, t, ErrBackend, err))
+	}
+	if !ok {
+		err := fmt.Errorf("kv doesn't support listing keys")
+		return d.fail(ctx, d.newError(loc, t, ErrBackend, err))
+	}
+
+	elemType := t.Elem()
+	children := d.keys.children(keys, prefix, !isStructType(elemType))
+	if len(children) == 0 && fp.required {
+		d.missing(loc)
+		return nil
+	}
+	if len(children) == 0 {
+		return nil
+	}
+
+	if f.IsNil() {
+		f.Set(reflect.MakeMapWithSize(t, len(children)))
+	}
+	for _, child := range children {
+		name := d.keys.unescape(child)
+		elem := reflect.New(elemType).Elem()
+		if err := d.decodeElem(ctx, elem, loc.elem(d.keys, child, name), fp.opts); err != nil {
+			return err
+		}
+		f.SetMapIndex(reflect.ValueOf(name).Convert(t.Key()), elem)
+	}
+	return nil
+}
+
+// decodeIndexed decodes slice or array field from indexed keys,
+// e.g. `key/0`, `key/1` and so on. Slice length is the number of
+// consecutive indexes starting from zero, array elements beyond the
+// last index keep their values.
+//
+// The default value is parsed as joined value if no element exists.
+func (d *Decoder) decodeIndexed(ctx context.Context, f reflect.Value, loc location, fp *fieldPlan) error {
+	elemFields, err := d.elemFields(f.Type().Elem())
+	if err != nil {
+		return err
+	}
+	n := f.Len()
+	found := true
+	if f.Kind() == reflect.Slice {
+		n, err = d.indexedLen(ctx, loc, elemFields)
+		found = n > 0
+	} else if fp.required || fp.spec.hasDef {
+		found, err = d.indexedExists(ctx, loc, n, elemFields)
+	}
+	if err != nil {
+		return d.fail(ctx, d.newError(loc, f.Type(), ErrBackend, err))
+	}
+	if !found && fp.required {
+		d.missing(loc)
+		return nil
+	}
+	if !found && fp.spec.hasDef {
+		def := NewStringValue(fp.spec.def)
+		if err := def.UnmarshalTo(f.Addr().Interface(), fp.opts); err != nil {
+			return d.fail(ctx, d.valueError(loc, f.Type(), def, err))
+		}
+		return nil
+	}
+	if f.Kind() == reflect.Slice {
+		if n == 0 {
+			return nil
+		}
+		f.Set(reflect.MakeSlice(f.Type(), n, n))
+	}
+
+	for i := 0; i < n; i++ {
+		idx := strconv.Itoa(i)
+		if err := d.decodeElem(ctx, f.Index(i), loc.elem(d.keys, idx, idx), fp.opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// elemFields returns plan fields of struct elements,
+// or nil for elements of other types.
+func (d *Decoder) elemFields(elemType reflect.Type) ([]fieldPlan, error) {
+	if !isStructType(elemType) {
+		return nil, nil
+	}
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	plan, err := d.plans.get(elemType)
+	if err != nil {
+		return nil, err
+	}
+	return plan.fields, nil
+}
+
+// indexedExists checks if any of first n elements exists, unlike
+// [Decoder.indexedLen] it doesn't stop on missing indexes.
+func (d *Decoder) indexedExists(ctx context.Context, loc location, n int, elemFields []fieldPlan) (bool, error) {
+	prefix := d.keys.listPrefix(loc.path)
+	keys, ok, err := d.list(ctx, prefix)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		for _, child := range d.keys.children(keys, prefix, elemFields == nil) {
+			if i, err := strconv.Atoi(child); err == nil && i >= 0 && i < n {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	for i := 0; i < n; i++ {
+		idx := strconv.Itoa(i)
+		elemLoc := loc.elem(d.keys, idx, idx)
+		keys := []string{elemLoc.key}
+		if elemFields != nil {
+			keys = planKeys(d.keys, elemLoc, elemFields, nil)
+		}
+		found, err := d.probe(ctx, keys)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// indexedLen returns the number of consecutive indexes under the location.
+//
+// It lists keys if the KV implements [Lister], or probes indexes
+// one by one until the first missing index otherwise. Elements of
+// struct type are probed by value keys of their plan fields, which
+// are nil for other elements.
+func (d *Decoder) indexedLen(ctx context.Context, loc location, elemFields []fieldPlan) (int, error) {
+	isStruct := elemFields != nil
+	prefix := d.keys.listPrefix(loc.path)
+	keys, ok, err := d.list(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		indexes := make(map[string]struct{})
+		for _, child := range d.keys.children(keys, prefix, !isStruct) {
+			indexes[child] = struct{}{}
+		}
+		var n int
+		for {
+			if _, ok := indexes[strconv.Itoa(n)]; !ok {
+				return n, nil
+			}
+			n++
+		}
+	}
+
+	for n := 0; ; n++ {
+		idx := strconv.Itoa(n)
+		elemLoc := loc.elem(d.keys, idx, idx)
+		keys := []string{elemLoc.key}
+		if isStruct {
+			// at least one value of the struct should exist.
+			keys = planKeys(d.keys, elemLoc, elemFields, nil)
+		}
+		found, err := d.probe(ctx, keys)
+		if err != nil {
+			return 0, err
+		}
+		if !found {
+			return n, nil
+		}
+	}
+}
+
+// structExists checks if any key of the struct field exists.
+//
+// It lists keys under the field if the KV supports listing,
+// or probes keys of the nested fields otherwise.
+func (d *Decoder) structExists(ctx context.Context, base, loc location, fp *fieldPlan) (bool, error) {
+	if !fp.spec.inline {
+		keys, ok, err := d.list(ctx, d.keys.listPrefix(loc.path))
+		if err != nil || ok {
+			return len(keys) > 0, err
+		}
+	}
+	if !fp.recursive {
+		return d.fieldsExist(ctx, base, fp.fields)
+	}
+	st := fp.typ
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	plan, err := d.plans.get(st)
+	if err != nil {
+		// reported by decoding
+		return true, nil
+	}
+	return d.probe(ctx, planKeys(d.keys, loc, plan.fields, nil))
+}
+
+// fieldsExist probes keys of value fields and first elements
+// of indexed fields, it's used if the KV doesn't support listing,
+// so map fields are not probed.
+func (d *Decoder) fieldsExist(ctx context.Context, base location, fields []fieldPlan) (bool, error) {
+	for i := range fields {
+		fp := &fields[i]
+		var found bool
+		var err error
+		switch fp.kind {
+		case valueField:
+			found, err = d.probe(ctx, []string{base.at(d.keys, fp).key})
+		case structFieldKind:
+			if fp.recursive {
+				continue
+			}
+			found, err = d.fieldsExist(ctx, base, fp.fields)
+		case indexedField:
+			var elemFields []fieldPlan
+			elemFields, err = d.elemFields(fp.typ.Elem())
+			if err != nil {
+				continue // reported by decoding
+			}
+			n := 1
+			if fp.typ.Kind() == reflect.Array {
+				n = fp.typ.Len()
+			}
+			found, err = d.indexedExists(ctx, base.at(d.keys, fp), n, elemFields)
+		}
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// list returns keys under the prefix, it returns false if the KV
+// doesn't implement [Lister] or returns [ErrNotSupported].
+func (d *Decoder) list(ctx context.Context, prefix string) ([]string, bool, error) {
+	lister, ok := d.kv.(Lister)
+	if !ok {
+		return nil, false, nil
+	}
+	keys, err := lister.List(ctx, prefix)
+	if errors.Is(err, ErrNotSupported) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("list keys %q: %w", prefix, err)
+	}
+	return keys, true, nil
+}
+
+// probe checks if any of the keys has a value.
+func (d *Decoder) probe(ctx context.Context, keys []string) (bool, error) {
+	for _, key := range keys {
+		value, err := d.kv.Get(ctx, key)
+		if err != nil {
+			return false, fmt.Errorf("get key %q: %w", key, err)
+		}
+		if value != NullValue {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// decodeElem decodes map, slice or array element.
+//
+// The v must be addressable, it's decoded as nested struct
+// or as a single value using the options of the field.
+func (d *Decoder) decodeElem(ctx context.Context, v reflect.Value, loc location, opts ValueUnmarshalOpts) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	if isStructType(v.Type()) {
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		plan, err := d.plans.get(v.Type())
+		if err != nil {
+			return err
+		}
+		return d.decodeStruct(ctx, loc, plan.fields, v)
 	}
 
+	d.consume(loc.key)
+	value, err := d.kv.Get(ctx, loc.key)
+	if err != nil {
+		return d.fail(ctx, d.newError(loc, v.Type(), ErrBackend, err))
+	}
+	value, derr := d.prepareValue(ctx, value, &loc, v.Type())
+	if derr != nil {
+		return d.fail(ctx, derr)
+	}
+	out := v.Addr().Interface()
+	if v.Kind() == reflect.Ptr {
+		out = v.Interface()
+	}
+	if err := value.UnmarshalTo(out, opts); err != nil {
+		return d.fail(ctx, d.valueError(loc, v.Type(), value, err))
+	}
 	return nil
 }
 
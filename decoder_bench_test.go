@@ -0,0 +1,97 @@
+package marshaler
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+type benchConfig struct {
+	Host    string        `kv:"host"`
+	Port    int           `kv:"port"`
+	Debug   bool          `kv:"debug"`
+	Timeout time.Duration `kv:"timeout"`
+	Params  []string      `kv:"params"`
+	Logger  struct {
+		Level  string `kv:"level"`
+		Output string `kv:"output"`
+	} `kv:"logger"`
+	DB *struct {
+		Host     string `kv:"host"`
+		Port     int    `kv:"port"`
+		User     string `kv:"user"`
+		Password string `kv:"password,secret"`
+		Pool     struct {
+			Min int           `kv:"min"`
+			Max int           `kv:"max"`
+			TTL time.Duration `kv:"ttl"`
+		} `kv:"pool"`
+	} `kv:"db"`
+	Backends []struct {
+		Host string `kv:"host"`
+		Port int    `kv:"port"`
+	} `kv:"backends"`
+}
+
+func benchKV() MapKV {
+	kv := MapKV{
+		"host":          "localhost",
+		"port":          "8080",
+		"debug":         "true",
+		"timeout":       "5s",
+		"params":        "a,b,c",
+		"logger/level":  "info",
+		"logger/output": "stdout",
+		"db/host":       "db.local",
+		"db/port":       "5432",
+		"db/user":       "user",
+		"db/password":   "secret",
+		"db/pool/min":   "1",
+		"db/pool/max":   "10",
+		"db/pool/ttl":   "1m",
+	}
+	for i := 0; i < 4; i++ {
+		kv["backends/"+strconv.Itoa(i)+"/host"] = "backend"
+		kv["backends/"+strconv.Itoa(i)+"/port"] = "80"
+	}
+	return kv
+}
+
+// BenchmarkDecoder compares decoding with cached plans
+// against compiling plans on each decode.
+func BenchmarkDecoder(b *testing.B) {
+	kv := benchKV()
+	b.Run("Cached", func(b *testing.B) {
+		dec, err := NewDecoder(kv)
+		if err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				var cfg benchConfig
+				if err := dec.Decode(&cfg); err != nil {
+					b.Errorf("unexpected error: %v", err)
+					return
+				}
+			}
+		})
+	})
+	b.Run("Uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				dec, err := NewDecoder(kv)
+				if err != nil {
+					b.Errorf("unexpected error: %v", err)
+					return
+				}
+				var cfg benchConfig
+				if err := dec.Decode(&cfg); err != nil {
+					b.Errorf("unexpected error: %v", err)
+					return
+				}
+			}
+		})
+	})
+}
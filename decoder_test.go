@@ -2,6 +2,17 @@ package marshaler
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -88,3 +99,1401 @@ func TestDecoder(t *testing.T) {
 		}
 	})
 }
+
+func TestDecoderMap(t *testing.T) {
+	type upstream struct {
+		Host string `kv:"host"`
+		Port int    `kv:"port"`
+	}
+	type target struct {
+		Upstreams map[string]upstream  `kv:"upstreams"`
+		Ptrs      map[string]*upstream `kv:"ptrs"`
+		Limits    map[string]int       `kv:"limits"`
+		Empty     map[string]string    `kv:"empty"`
+	}
+	t.Run("Decode", func(t *testing.T) {
+		kv := MapKV{
+			"upstreams/a/host": "a.local",
+			"upstreams/a/port": "80",
+			"upstreams/b/host": "b.local",
+			"ptrs/c/host":      "c.local",
+			"limits/read":      "10",
+			"limits/write":     "20",
+			"limits/nested/x":  "ignored",
+		}
+		var cfg target
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cfg.Upstreams) != 2 {
+			t.Fatalf("expected 2 upstreams, got %d", len(cfg.Upstreams))
+		}
+		if cfg.Upstreams["a"] != (upstream{Host: "a.local", Port: 80}) {
+			t.Fatalf("unexpected upstream a: %+v", cfg.Upstreams["a"])
+		}
+		if cfg.Upstreams["b"] != (upstream{Host: "b.local"}) {
+			t.Fatalf("unexpected upstream b: %+v", cfg.Upstreams["b"])
+		}
+		if p := cfg.Ptrs["c"]; p == nil || p.Host != "c.local" {
+			t.Fatalf("unexpected ptr c: %+v", p)
+		}
+		if len(cfg.Limits) != 2 || cfg.Limits["read"] != 10 || cfg.Limits["write"] != 20 {
+			t.Fatalf("unexpected limits: %v", cfg.Limits)
+		}
+		if cfg.Empty != nil {
+			t.Fatalf("expected nil map, got %v", cfg.Empty)
+		}
+	})
+	t.Run("RoundTrip", func(t *testing.T) {
+		src := target{
+			Upstreams: map[string]upstream{"x": {Host: "x.local", Port: 1}},
+			Limits:    map[string]int{"read": 1},
+		}
+		kv := MapKV{}
+		if err := Marshal(kv, &src); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var dst target
+		if err := Unmarshal(kv, &dst); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if dst.Upstreams["x"] != src.Upstreams["x"] || dst.Limits["read"] != 1 {
+			t.Fatalf("expected %+v, got %+v", src, dst)
+		}
+	})
+	t.Run("NoLister", func(t *testing.T) {
+		var cfg target
+		if err := Unmarshal(newKVStub(), &cfg); err == nil {
+			t.Fatalf("expected error, got nil")
+		}
+	})
+	t.Run("Nested", func(t *testing.T) {
+		targets := []any{
+			&struct {
+				Groups map[string]map[string]string `kv:"groups"`
+			}{},
+			&struct {
+				Groups map[string][]upstream `kv:"groups"`
+			}{},
+			&struct {
+				Groups map[string][2]int `kv:"groups"`
+			}{},
+			&struct {
+				Groups map[string][]int `kv:"groups,indexed"`
+			}{},
+		}
+		kv := MapKV{"groups/a/0/host": "a.local"}
+		for _, cfg := range targets {
+			if err := Unmarshal(kv, cfg); !errors.Is(err, ErrUnsupportedType) {
+				t.Fatalf("unexpected error of %T: %v", cfg, err)
+			}
+			if err := Marshal(MapKV{}, cfg); !errors.Is(err, ErrUnsupportedType) {
+				t.Fatalf("unexpected error of %T: %v", cfg, err)
+			}
+		}
+		// joined slices are single values.
+		var cfg struct {
+			Groups map[string][]int `kv:"groups"`
+		}
+		if err := Unmarshal(MapKV{"groups/a": "1,2"}, &cfg); err != nil || len(cfg.Groups["a"]) != 2 {
+			t.Fatalf("unexpected result: %v, %v", cfg.Groups, err)
+		}
+	})
+}
+
+func TestDecoderIndexed(t *testing.T) {
+	type backend struct {
+		Host string `kv:"host"`
+		Port int    `kv:"port"`
+	}
+	type target struct {
+		Backends []backend  `kv:"backends"`
+		Ptrs     []*backend `kv:"ptrs"`
+		Ports    []int      `kv:"ports,indexed"`
+		Tags     []string   `kv:"tags"`
+		Fixed    [3]string  `kv:"fixed"`
+	}
+	data := map[string]string{
+		"backends/0/host": "a.local",
+		"backends/0/port": "80",
+		"backends/1/host": "b.local",
+		"backends/3/host": "gap",
+		"ptrs/0/port":     "81",
+		"ports/0":         "1",
+		"ports/1":         "2",
+		"tags":            "a,b",
+		"fixed/0":         "x",
+		"fixed/2":         "z",
+	}
+	check := func(t *testing.T, cfg target) {
+		t.Helper()
+		if len(cfg.Backends) != 2 {
+			t.Fatalf("expected 2 backends, got %d", len(cfg.Backends))
+		}
+		if cfg.Backends[0] != (backend{Host: "a.local", Port: 80}) {
+			t.Fatalf("unexpected backend 0: %+v", cfg.Backends[0])
+		}
+		if cfg.Backends[1] != (backend{Host: "b.local"}) {
+			t.Fatalf("unexpected backend 1: %+v", cfg.Backends[1])
+		}
+		if len(cfg.Ptrs) != 1 || cfg.Ptrs[0].Port != 81 {
+			t.Fatalf("unexpected ptrs: %+v", cfg.Ptrs)
+		}
+		if len(cfg.Ports) != 2 || cfg.Ports[0] != 1 || cfg.Ports[1] != 2 {
+			t.Fatalf("unexpected ports: %v", cfg.Ports)
+		}
+		if len(cfg.Tags) != 2 || cfg.Tags[1] != "b" {
+			t.Fatalf("unexpected tags: %v", cfg.Tags)
+		}
+		if cfg.Fixed != [3]string{"x", "", "z"} {
+			t.Fatalf("unexpected fixed: %v", cfg.Fixed)
+		}
+	}
+	t.Run("Lister", func(t *testing.T) {
+		var cfg target
+		if err := Unmarshal(MapKV(data), &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		check(t, cfg)
+	})
+	t.Run("Probe", func(t *testing.T) {
+		kv := newKVStub()
+		for k, v := range data {
+			kv.With(k, v)
+		}
+		var cfg target
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		check(t, cfg)
+	})
+	t.Run("ProbeResolve", func(t *testing.T) {
+		// probing doesn't resolve references of elements.
+		kv := newKVStub().
+			With("backends/0/host", "secret:a").
+			With("backends/1/port", "80")
+		var calls int
+		dec, err := NewDecoder(kv, WithResolver("secret", ResolverFunc(func(_ context.Context, ref string) (Value, error) {
+			calls++
+			return NewStringValue(ref + ".local"), nil
+		})))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg struct {
+			Backends []backend `kv:"backends"`
+		}
+		if err := dec.Decode(&cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cfg.Backends) != 2 || cfg.Backends[0].Host != "a.local" || cfg.Backends[1].Port != 80 {
+			t.Fatalf("unexpected backends: %+v", cfg.Backends)
+		}
+		if calls != 1 {
+			t.Fatalf("expected single resolve, got %d", calls)
+		}
+	})
+	t.Run("RoundTrip", func(t *testing.T) {
+		src := target{
+			Backends: []backend{{Host: "x", Port: 1}, {Host: "y", Port: 2}},
+			Ports:    []int{5, 6, 7},
+			Tags:     []string{"t"},
+		}
+		kv := MapKV{}
+		if err := Marshal(kv, &src); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if kv["backends/1/host"] != "y" || kv["ports/2"] != "7" {
+			t.Fatalf("unexpected kv: %v", kv)
+		}
+		var dst target
+		if err := Unmarshal(kv, &dst); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(dst.Backends) != 2 || dst.Backends[1] != src.Backends[1] || len(dst.Ports) != 3 {
+			t.Fatalf("expected %+v, got %+v", src, dst)
+		}
+	})
+}
+
+func TestDecoderSliceSep(t *testing.T) {
+	type target struct {
+		Default []int           `kv:"default"`
+		Custom  []time.Duration `kv:"custom,sep=;"`
+	}
+	kv := MapKV{
+		"default": "1|2",
+		"custom":  "1s;2s",
+	}
+	dec, err := NewDecoder(kv, WithSliceSeparator("|"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var cfg target
+	if err := dec.Decode(&cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Default) != 2 || cfg.Default[1] != 2 {
+		t.Fatalf("unexpected default: %v", cfg.Default)
+	}
+	if len(cfg.Custom) != 2 || cfg.Custom[1] != 2*time.Second {
+		t.Fatalf("unexpected custom: %v", cfg.Custom)
+	}
+
+	t.Run("Elements", func(t *testing.T) {
+		type target struct {
+			Map     map[string][]int `kv:"map,sep=;"`
+			Indexed [][]int          `kv:"indexed,indexed,sep=;"`
+		}
+		kv := MapKV{"map/a": "1;2", "indexed/0": "3;4"}
+		var cfg target
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := target{Map: map[string][]int{"a": {1, 2}}, Indexed: [][]int{{3, 4}}}
+		if !reflect.DeepEqual(cfg, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, cfg)
+		}
+		out := MapKV{}
+		if err := Marshal(out, cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(out, kv) {
+			t.Fatalf("expected %v, got %v", kv, out)
+		}
+	})
+
+	out := MapKV{}
+	enc, err := NewEncoder(out, WithSliceSeparator("|"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := enc.Encode(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out["default"] != "1|2" || out["custom"] != "1s;2s" {
+		t.Fatalf("unexpected encoded values: %v", out)
+	}
+}
+
+func TestDecoderDefault(t *testing.T) {
+	type target struct {
+		Port    int           `kv:"port,default=8080"`
+		Host    *string       `kv:"host" default:"localhost"`
+		Params  []string      `kv:"params,default=a,b"`
+		Ports   []int         `kv:"ports,indexed,default=1,2"`
+		Weights [2]int        `kv:"weights,default=3,4"`
+		Timeout time.Duration `kv:"timeout,default=5s"`
+		Set     string        `kv:"set,default=unused"`
+		Logger  *struct {
+			Level string `kv:"level,default=info"`
+		} `kv:"logger"`
+	}
+	kv := MapKV{"set": "value"}
+	var cfg target
+	if err := Unmarshal(kv, &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Port != 8080 {
+		t.Fatalf("expected %d, got %d", 8080, cfg.Port)
+	}
+	if cfg.Host == nil || *cfg.Host != "localhost" {
+		t.Fatalf("expected %q, got %v", "localhost", cfg.Host)
+	}
+	if len(cfg.Params) != 2 || cfg.Params[1] != "b" {
+		t.Fatalf("expected [a b], got %v", cfg.Params)
+	}
+	if len(cfg.Ports) != 2 || cfg.Ports[1] != 2 {
+		t.Fatalf("expected [1 2], got %v", cfg.Ports)
+	}
+	if cfg.Weights != [2]int{3, 4} {
+		t.Fatalf("expected [3 4], got %v", cfg.Weights)
+	}
+	if cfg.Timeout != 5*time.Second {
+		t.Fatalf("expected %v, got %v", 5*time.Second, cfg.Timeout)
+	}
+	if cfg.Set != "value" {
+		t.Fatalf("expected %q, got %q", "value", cfg.Set)
+	}
+	if cfg.Logger.Level != "info" {
+		t.Fatalf("expected %q, got %q", "info", cfg.Logger.Level)
+	}
+
+	t.Run("ArrayElements", func(t *testing.T) {
+		// the default is not used if any element exists.
+		var cfg target
+		if err := Unmarshal(MapKV{"weights/1": "5"}, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Weights != [2]int{0, 5} {
+			t.Fatalf("expected [0 5], got %v", cfg.Weights)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		var cfg struct {
+			Port int `kv:"port,default=invalid"`
+		}
+		if err := Unmarshal(MapKV{}, &cfg); err == nil {
+			t.Fatalf("expected error, got nil")
+		}
+	})
+}
+
+func TestDecoderRequired(t *testing.T) {
+	type target struct {
+		Host   string            `kv:"host,required"`
+		Port   int               `kv:"port,required"`
+		Opt    string            `kv:"opt"`
+		Params []int             `kv:"params,indexed,required"`
+		Tags   map[string]string `kv:"tags,required"`
+		Logger struct {
+			Level  string `kv:"level,required"`
+			Output string `kv:"output,omitempty"`
+			Format string `kv:"format,default=json"`
+		} `kv:"logger"`
+	}
+	t.Run("Missing", func(t *testing.T) {
+		var cfg target
+		err := Unmarshal(MapKV{"port": "80"}, &cfg)
+		var missingErr *MissingKeysError
+		if !errors.As(err, &missingErr) {
+			t.Fatalf("expected MissingKeysError, got %v", err)
+		}
+		expected := []string{"host", "params", "tags", "logger/level"}
+		if !slices.Equal(missingErr.Keys, expected) {
+			t.Fatalf("expected %v, got %v", expected, missingErr.Keys)
+		}
+		if cfg.Port != 80 {
+			t.Fatalf("expected %d, got %d", 80, cfg.Port)
+		}
+	})
+	t.Run("RequireAll", func(t *testing.T) {
+		kv := MapKV{
+			"host":         "localhost",
+			"port":         "80",
+			"params/0":     "1",
+			"tags/a":       "b",
+			"logger/level": "info",
+		}
+		var cfg target
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		dec, err := NewDecoder(kv, WithRequireAll())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = dec.Decode(&cfg)
+		var missingErr *MissingKeysError
+		if !errors.As(err, &missingErr) {
+			t.Fatalf("expected MissingKeysError, got %v", err)
+		}
+		if !slices.Equal(missingErr.Keys, []string{"opt"}) {
+			t.Fatalf("expected %v, got %v", []string{"opt"}, missingErr.Keys)
+		}
+	})
+	t.Run("Struct", func(t *testing.T) {
+		type tls struct {
+			Cert string `kv:"cert"`
+			Key  string `kv:"key"`
+		}
+		var cfg struct {
+			TLS   tls  `kv:"tls,required"`
+			Proxy *tls `kv:"proxy,required"`
+		}
+		err := Unmarshal(MapKV{}, &cfg)
+		var missingErr *MissingKeysError
+		if !errors.As(err, &missingErr) {
+			t.Fatalf("expected MissingKeysError, got %v", err)
+		}
+		expected := []string{"tls", "proxy"}
+		if !slices.Equal(missingErr.Keys, expected) {
+			t.Fatalf("expected %v, got %v", expected, missingErr.Keys)
+		}
+		kv := newKVStub().With("tls/key", "tls.key").With("proxy/cert", "proxy.pem")
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.TLS.Key != "tls.key" || cfg.Proxy == nil || cfg.Proxy.Cert != "proxy.pem" {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+	t.Run("StructCollections", func(t *testing.T) {
+		// structs of maps and indexed slices only.
+		type target struct {
+			N struct {
+				M map[string]string `kv:"m"`
+				L []int             `kv:"l,indexed"`
+			} `kv:"n,required"`
+		}
+		var cfg target
+		if err := Unmarshal(MapKV{"n/m/a": "1", "n/l/0": "2"}, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.N.M["a"] != "1" || len(cfg.N.L) != 1 {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+		var probed struct {
+			N struct {
+				L []int `kv:"l,indexed"`
+			} `kv:"n,required"`
+		}
+		if err := Unmarshal(newKVStub().With("n/l/0", "2"), &probed); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var missingErr *MissingKeysError
+		if err := Unmarshal(MapKV{"other": "x"}, &cfg); !errors.As(err, &missingErr) {
+			t.Fatalf("expected MissingKeysError, got %v", err)
+		}
+	})
+	t.Run("Array", func(t *testing.T) {
+		var cfg struct {
+			Arr   [2]int `kv:"arr,required"`
+			Other [2]int `kv:"other"`
+		}
+		err := Unmarshal(MapKV{}, &cfg)
+		var missingErr *MissingKeysError
+		if !errors.As(err, &missingErr) || !slices.Equal(missingErr.Keys, []string{"arr"}) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, kv := range []KV{MapKV{"arr/1": "2"}, newKVStub().With("arr/1", "2")} {
+			if err := Unmarshal(kv, &cfg); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Arr != [2]int{0, 2} {
+				t.Fatalf("unexpected array: %v", cfg.Arr)
+			}
+		}
+		dec, err := NewDecoder(MapKV{"arr/0": "1"}, WithRequireAll())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = dec.Decode(&cfg)
+		if !errors.As(err, &missingErr) || !slices.Equal(missingErr.Keys, []string{"other"}) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestDecoderKeys(t *testing.T) {
+	type target struct {
+		Host   string `kv:"host"`
+		Logger struct {
+			Level string `kv:"level"`
+		} `kv:"logger"`
+		Limits map[string]int `kv:"limits"`
+	}
+	t.Run("Separator", func(t *testing.T) {
+		kv := MapKV{
+			"app.host":         "localhost",
+			"app.logger.level": "info",
+			"app.limits.read":  "1",
+		}
+		dec, err := NewDecoder(kv, WithSeparator("."), WithPrefix("app"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg target
+		if err := dec.Decode(&cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Host != "localhost" || cfg.Logger.Level != "info" || cfg.Limits["read"] != 1 {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+	t.Run("KeyFunc", func(t *testing.T) {
+		kv := MapKV{
+			"APP_HOST":         "localhost",
+			"APP_LOGGER_LEVEL": "info",
+			"APP_LIMITS_READ":  "1",
+		}
+		keyFunc := func(path []string) string {
+			return strings.ToUpper(strings.Join(path, "_"))
+		}
+		dec, err := NewDecoder(kv, WithSeparator("_"), WithPrefix("APP"), WithKeyFunc(keyFunc))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg target
+		if err := dec.Decode(&cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Host != "localhost" || cfg.Logger.Level != "info" || cfg.Limits["READ"] != 1 {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+	t.Run("Escape", func(t *testing.T) {
+		src := target{Limits: map[string]int{"a/b": 1, "100%": 2}}
+		kv := MapKV{}
+		if err := Marshal(kv, &src); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if kv["limits/a%2Fb"] != "1" || kv["limits/100%25"] != "2" {
+			t.Fatalf("unexpected kv: %v", kv)
+		}
+		var dst target
+		if err := Unmarshal(kv, &dst); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if dst.Limits["a/b"] != 1 || dst.Limits["100%"] != 2 {
+			t.Fatalf("unexpected limits: %v", dst.Limits)
+		}
+	})
+	t.Run("NilKeyFunc", func(t *testing.T) {
+		if _, err := NewDecoder(MapKV{}, WithKeyFunc(nil)); !errors.Is(err, ErrNilKeyFunc) {
+			t.Fatalf("expected %v, got %v", ErrNilKeyFunc, err)
+		}
+	})
+}
+
+func TestDecoderUnmarshalers(t *testing.T) {
+	type target struct {
+		Addr  netip.Addr   `kv:"addr"`
+		Level slog.Level   `kv:"level"`
+		Big   big.Int      `kv:"big"`
+		Ptr   *big.Int     `kv:"ptr"`
+		Addrs []netip.Addr `kv:"addrs"`
+	}
+	kv := MapKV{
+		"addr":  "10.0.0.1",
+		"level": "DEBUG",
+		"big":   "42",
+		"ptr":   "-1",
+		"addrs": "::1,127.0.0.1",
+	}
+	var cfg target
+	if err := Unmarshal(kv, &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Addr != netip.MustParseAddr("10.0.0.1") || cfg.Level != slog.LevelDebug {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if cfg.Big.Int64() != 42 || cfg.Ptr.Int64() != -1 {
+		t.Fatalf("unexpected big ints: %v, %v", &cfg.Big, cfg.Ptr)
+	}
+	if len(cfg.Addrs) != 2 || cfg.Addrs[1] != netip.MustParseAddr("127.0.0.1") {
+		t.Fatalf("unexpected addrs: %v", cfg.Addrs)
+	}
+
+	out := MapKV{}
+	if err := Marshal(out, &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for k, v := range kv {
+		if out[k] != v {
+			t.Fatalf("expected %q for key %q, got %q", v, k, out[k])
+		}
+	}
+}
+
+type errKV struct{}
+
+func (errKV) Get(ctx context.Context, key string) (Value, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestDecoderErrors(t *testing.T) {
+	type target struct {
+		Port   int `kv:"port"`
+		Logger struct {
+			Level slog.Level `kv:"level"`
+		} `kv:"logger"`
+		Password int `kv:"password,secret"`
+		Backends []struct {
+			Port int `kv:"port"`
+		} `kv:"backends"`
+		Ch       chan int `kv:"ch"`
+		Required string   `kv:"required,required"`
+	}
+	kv := MapKV{
+		"port":            "http",
+		"logger/level":    "loud",
+		"password":        "hunter2",
+		"backends/0/port": "80",
+		"backends/1/port": "x",
+		"ch":              "1",
+	}
+	t.Run("First", func(t *testing.T) {
+		var cfg target
+		err := Unmarshal(kv, &cfg)
+		var derr *DecodeError
+		if !errors.As(err, &derr) {
+			t.Fatalf("expected DecodeError, got %v", err)
+		}
+		if !errors.Is(err, ErrParse) {
+			t.Fatalf("expected ErrParse, got %v", err)
+		}
+		if derr.Field != "Port" || derr.Key != "port" || derr.Value != "http" || derr.Type != reflect.TypeOf(0) {
+			t.Fatalf("unexpected error: %+v", derr)
+		}
+	})
+	t.Run("Collect", func(t *testing.T) {
+		dec, err := NewDecoder(kv, WithCollectErrors())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg target
+		err = dec.Decode(&cfg)
+		errs := err.(interface{ Unwrap() []error }).Unwrap()
+		if len(errs) != 6 {
+			t.Fatalf("expected 6 errors, got %d: %v", len(errs), err)
+		}
+		expected := []struct {
+			field string
+			kind  error
+		}{
+			{"Port", ErrParse},
+			{"Logger.Level", ErrParse},
+			{"Password", ErrParse},
+			{"Backends[1].Port", ErrParse},
+			{"Ch", ErrUnsupportedType},
+		}
+		for i, exp := range expected {
+			var derr *DecodeError
+			if !errors.As(errs[i], &derr) {
+				t.Fatalf("expected DecodeError, got %v", errs[i])
+			}
+			if derr.Field != exp.field || !errors.Is(derr, exp.kind) {
+				t.Fatalf("expected %s error of %s, got %v", exp.kind, exp.field, derr)
+			}
+		}
+		if !errors.Is(errs[5], ErrMissingKey) {
+			t.Fatalf("expected ErrMissingKey, got %v", errs[5])
+		}
+		if cfg.Backends[0].Port != 80 {
+			t.Fatalf("expected %d, got %d", 80, cfg.Backends[0].Port)
+		}
+	})
+	t.Run("Redacted", func(t *testing.T) {
+		var cfg struct {
+			Password int `kv:"password,secret"`
+		}
+		err := Unmarshal(kv, &cfg)
+		var derr *DecodeError
+		if !errors.As(err, &derr) {
+			t.Fatalf("expected DecodeError, got %v", err)
+		}
+		if !derr.Redacted || derr.Value != "" || strings.Contains(err.Error(), "hunter2") {
+			t.Fatalf("expected redacted error, got %v", err)
+		}
+
+		dec, err := NewDecoder(kv, WithRedactedValues())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var port struct {
+			Port int `kv:"port"`
+		}
+		if err := dec.Decode(&port); err == nil || strings.Contains(err.Error(), "http") {
+			t.Fatalf("expected redacted error, got %v", err)
+		}
+	})
+	t.Run("Backend", func(t *testing.T) {
+		var cfg target
+		err := Unmarshal(errKV{}, &cfg)
+		if !errors.Is(err, ErrBackend) {
+			t.Fatalf("expected ErrBackend, got %v", err)
+		}
+	})
+}
+
+func TestDecoderConcurrent(t *testing.T) {
+	dec, err := NewDecoder(benchKV())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var cfg benchConfig
+			if err := dec.Decode(&cfg); err != nil {
+				errs <- err
+				return
+			}
+			if cfg.DB.Pool.Max != 10 || len(cfg.Backends) != 4 {
+				errs <- fmt.Errorf("unexpected config: %+v", cfg)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// fetcherKV is a KV which supports only prefix fetching.
+type fetcherKV struct {
+	data    map[string]string
+	fetches []string
+}
+
+func (k *fetcherKV) Get(ctx context.Context, key string) (Value, error) {
+	return nil, errors.New("unexpected get")
+}
+
+func (k *fetcherKV) FetchPrefix(ctx context.Context, prefix string) (map[string]Value, error) {
+	k.fetches = append(k.fetches, prefix)
+	values := make(map[string]Value)
+	for key, val := range k.data {
+		if strings.HasPrefix(key, prefix) {
+			values[key] = NewStringValue(val)
+		}
+	}
+	return values, nil
+}
+
+// getFetcherKV is a MapKV which records prefix fetches.
+type getFetcherKV struct {
+	MapKV
+	fetches []string
+}
+
+func (k *getFetcherKV) FetchPrefix(ctx context.Context, prefix string) (map[string]Value, error) {
+	k.fetches = append(k.fetches, prefix)
+	return nil, errors.New("unexpected fetch")
+}
+
+func TestDecoderPrefetch(t *testing.T) {
+	type target struct {
+		Host   string         `kv:"host"`
+		Limits map[string]int `kv:"limits"`
+		Ports  []int          `kv:"ports,indexed"`
+		Logger struct {
+			Level string `kv:"level"`
+		} `kv:"logger"`
+	}
+	kv := &fetcherKV{data: map[string]string{
+		"app/host":         "localhost",
+		"app/limits/read":  "1",
+		"app/ports/0":      "80",
+		"app/logger/level": "info",
+		"other/host":       "other",
+	}}
+	dec, err := NewDecoder(kv, WithPrefix("app"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var cfg target
+	if err := dec.Decode(&cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(kv.fetches, []string{"app/"}) {
+		t.Fatalf("expected single fetch of %q, got %v", "app/", kv.fetches)
+	}
+	if cfg.Host != "localhost" || cfg.Limits["read"] != 1 || len(cfg.Ports) != 1 || cfg.Logger.Level != "info" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	t.Run("NoPrefix", func(t *testing.T) {
+		kv := &getFetcherKV{MapKV: MapKV{"host": "localhost"}}
+		var cfg target
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(kv.fetches) != 0 {
+			t.Fatalf("unexpected fetches without prefix: %v", kv.fetches)
+		}
+		if cfg.Host != "localhost" {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+}
+
+// slowKV is a KV with request latency, which tracks parallel requests.
+type slowKV struct {
+	MapKV
+	delay time.Duration
+
+	mux     sync.Mutex
+	active  int
+	maxSeen int
+}
+
+func (k *slowKV) Get(ctx context.Context, key string) (Value, error) {
+	k.mux.Lock()
+	k.active++
+	k.maxSeen = max(k.maxSeen, k.active)
+	k.mux.Unlock()
+	defer func() {
+		k.mux.Lock()
+		k.active--
+		k.mux.Unlock()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(k.delay):
+	}
+	if key == "fail" {
+		return nil, errors.New("fail")
+	}
+	return k.MapKV.Get(ctx, key)
+}
+
+func TestDecoderConcurrency(t *testing.T) {
+	t.Run("Decode", func(t *testing.T) {
+		kv := &slowKV{MapKV: benchKV(), delay: time.Millisecond}
+		dec, err := NewDecoder(kv, WithConcurrency(4))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg benchConfig
+		if err := dec.Decode(&cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Host != "localhost" || cfg.DB.Pool.TTL != time.Minute || len(cfg.Backends) != 4 {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+		if kv.maxSeen < 2 || kv.maxSeen > 4 {
+			t.Fatalf("expected 2..4 parallel requests, got %d", kv.maxSeen)
+		}
+	})
+	t.Run("Error", func(t *testing.T) {
+		kv := &slowKV{MapKV: MapKV{"port": "x"}}
+		dec, err := NewDecoder(kv, WithConcurrency(2), WithCollectErrors())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg struct {
+			Fail string `kv:"fail"`
+			Port int    `kv:"port"`
+		}
+		err = dec.Decode(&cfg)
+		errs := err.(interface{ Unwrap() []error }).Unwrap()
+		if len(errs) != 2 || !errors.Is(errs[0], ErrBackend) || !errors.Is(errs[1], ErrParse) {
+			t.Fatalf("unexpected errors: %v", err)
+		}
+	})
+	t.Run("Cancel", func(t *testing.T) {
+		kv := &slowKV{MapKV: benchKV(), delay: time.Second}
+		dec, err := NewDecoder(kv, WithConcurrency(2))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		var cfg benchConfig
+		if err := dec.DecodeContext(ctx, &cfg); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := NewDecoder(MapKV{}, WithConcurrency(0)); !errors.Is(err, ErrConcurrency) {
+			t.Fatalf("expected %v, got %v", ErrConcurrency, err)
+		}
+	})
+}
+
+func TestDecoderNameStrategy(t *testing.T) {
+	type target struct {
+		Host       string
+		MaxConns   int
+		HTTPServer struct {
+			ReadTimeout time.Duration
+		}
+		Tagged  string `kv:"custom"`
+		Options string `kv:",required"`
+		Skipped string `kv:"-"`
+	}
+	kv := MapKV{
+		"host":                     "localhost",
+		"max_conns":                "10",
+		"http_server/read_timeout": "1s",
+		"custom":                   "tagged",
+		"options":                  "opts",
+		"Options":                  "exact",
+		"skipped":                  "unexpected",
+		"-":                        "unexpected",
+	}
+	dec, err := NewDecoder(kv, WithNameStrategy(SnakeCase))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var cfg target
+	if err := dec.Decode(&cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Host != "localhost" || cfg.MaxConns != 10 || cfg.HTTPServer.ReadTimeout != time.Second {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if cfg.Tagged != "tagged" || cfg.Options != "opts" || cfg.Skipped != "" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		var cfg target
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Host != "" || cfg.Tagged != "tagged" || cfg.Skipped != "" {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+	t.Run("Custom", func(t *testing.T) {
+		upper := func(name string) string { return strings.ToUpper(name) }
+		var cfg struct {
+			Host string
+		}
+		if err := Unmarshal(MapKV{"HOST": "h"}, &cfg); err != nil || cfg.Host != "" {
+			t.Fatalf("unexpected result: %+v, %v", cfg, err)
+		}
+		dec, err := NewDecoder(MapKV{"HOST": "h"}, WithNameStrategy(upper))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := dec.Decode(&cfg); err != nil || cfg.Host != "h" {
+			t.Fatalf("unexpected result: %+v, %v", cfg, err)
+		}
+	})
+}
+
+type testTimeouts struct {
+	Read  time.Duration `kv:"read_timeout"`
+	Write time.Duration `kv:"write_timeout"`
+}
+
+type TLSConfig struct {
+	Cert string `kv:"cert"`
+	Key  string `kv:"key"`
+}
+
+type testListen struct {
+	Addr string `kv:"addr"`
+}
+
+func TestDecoderInline(t *testing.T) {
+	kv := MapKV{
+		"server/addr":          "localhost:8080",
+		"server/read_timeout":  "1s",
+		"server/write_timeout": "2s",
+		"server/cert":          "cert.pem",
+		"server/key":           "key.pem",
+		"server/tls/cert":      "nested.pem",
+	}
+	t.Run("Embedded", func(t *testing.T) {
+		var cfg struct {
+			Server struct {
+				testListen
+				*TLSConfig
+				Timeouts testTimeouts `kv:",inline"`
+				Nested   TLSConfig    `kv:"tls"`
+			} `kv:"server"`
+		}
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		srv := cfg.Server
+		if srv.Addr != "localhost:8080" || srv.Cert != "cert.pem" || srv.Key != "key.pem" {
+			t.Fatalf("unexpected config: %+v", srv)
+		}
+		if srv.Timeouts.Read != time.Second || srv.Timeouts.Write != 2*time.Second {
+			t.Fatalf("unexpected timeouts: %+v", srv.Timeouts)
+		}
+		if srv.Nested.Cert != "nested.pem" {
+			t.Fatalf("expected %q, got %q", "nested.pem", srv.Nested.Cert)
+		}
+	})
+	t.Run("Squash", func(t *testing.T) {
+		var cfg struct {
+			Timeouts testTimeouts `kv:"server,squash"`
+			Server   struct {
+				TLS TLSConfig `kv:"tls"`
+			} `kv:"server"`
+		}
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Timeouts.Read != 0 || cfg.Server.TLS.Cert != "nested.pem" {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+	t.Run("Tagged", func(t *testing.T) {
+		var cfg struct {
+			TLSConfig `kv:"server"`
+		}
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Cert != "cert.pem" {
+			t.Fatalf("expected %q, got %q", "cert.pem", cfg.Cert)
+		}
+	})
+	t.Run("Collision", func(t *testing.T) {
+		var cfg struct {
+			TLSConfig
+			Key string `kv:"key"`
+		}
+		err := Unmarshal(kv, &cfg)
+		if err == nil || !strings.Contains(err.Error(), `key "key" of field Key collides with field TLSConfig.Key`) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = Marshal(MapKV{}, &cfg)
+		if err == nil || !strings.Contains(err.Error(), "collides") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	t.Run("NotStruct", func(t *testing.T) {
+		var cfg struct {
+			Addr string `kv:"addr,inline"`
+		}
+		if err := Unmarshal(kv, &cfg); err == nil || !strings.Contains(err.Error(), "is not a struct") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestDecoderStrict(t *testing.T) {
+	type target struct {
+		Logger struct {
+			Level string `kv:"level"`
+		} `kv:"logger"`
+		Upstreams map[string]string `kv:"upstreams"`
+		Ports     []int             `kv:"ports,indexed"`
+	}
+	kv := MapKV{
+		"app/logger/level":  "info",
+		"app/loger/level":   "debug",
+		"app/upstreams/a":   "a.local",
+		"app/upstreams/b/c": "nested",
+		"app/ports/0":       "80",
+		"app/ports/2":       "443",
+		"other/key":         "ignored",
+	}
+	t.Run("Error", func(t *testing.T) {
+		dec, err := NewDecoder(kv, WithPrefix("app"), WithStrict())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg target
+		err = dec.Decode(&cfg)
+		var unknown *UnknownKeysError
+		if !errors.As(err, &unknown) || !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := "app/loger/level, app/ports/2, app/upstreams/b/c"
+		if keys := strings.Join(unknown.Keys, ", "); keys != expected {
+			t.Fatalf("expected %q, got %q", expected, keys)
+		}
+		if cfg.Logger.Level != "info" || cfg.Upstreams["a"] != "a.local" || len(cfg.Ports) != 1 {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+	t.Run("Handler", func(t *testing.T) {
+		var unknown []string
+		dec, err := NewDecoder(kv, WithPrefix("app"),
+			WithUnknownKeysHandler(func(keys []string) { unknown = keys }))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg target
+		if err := dec.Decode(&cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(unknown) != 3 {
+			t.Fatalf("unexpected unknown keys: %v", unknown)
+		}
+	})
+	t.Run("NoUnknown", func(t *testing.T) {
+		dec, err := NewDecoder(MapKV{"logger/level": "info"}, WithStrict())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg target
+		if err := dec.Decode(&cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	t.Run("NestedUnderValue", func(t *testing.T) {
+		dec, err := NewDecoder(MapKV{"logger": "x", "logger/typo": "y"}, WithStrict())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg struct {
+			Logger string `kv:"logger"`
+		}
+		var unknown *UnknownKeysError
+		if err := dec.Decode(&cfg); !errors.As(err, &unknown) || strings.Join(unknown.Keys, ", ") != "logger/typo" {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Layouts", func(t *testing.T) {
+		// both layouts of the same slice, e.g. by file backends.
+		kv := MapKV{
+			"joined": "1,2", "joined/0": "1", "joined/1": "2",
+			"indexed": "3,4", "indexed/0": "3", "indexed/1": "4",
+		}
+		dec, err := NewDecoder(kv, WithStrict())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg struct {
+			Joined  []int  `kv:"joined"`
+			Indexed [2]int `kv:"indexed"`
+		}
+		if err := dec.Decode(&cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cfg.Joined) != 2 || cfg.Indexed != [2]int{3, 4} {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+	t.Run("NoLister", func(t *testing.T) {
+		dec, err := NewDecoder(&kvStub{data: map[string]string{"logger/level": "info"}}, WithStrict())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg struct {
+			Level string `kv:"logger/level"`
+		}
+		if err := dec.Decode(&cfg); !errors.Is(err, ErrBackend) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	t.Run("NilHandler", func(t *testing.T) {
+		if _, err := NewDecoder(kv, WithUnknownKeysHandler(nil)); !errors.Is(err, ErrNilHandler) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+// textKV returns values which are not StringValue,
+// like backends keeping native types of scalars.
+type textKV map[string]string
+
+func (kv textKV) Get(ctx context.Context, key string) (Value, error) {
+	val, ok := kv[key]
+	if !ok {
+		return NullValue, nil
+	}
+	return textValue(val), nil
+}
+
+type textValue string
+
+func (v textValue) UnmarshalTo(out any, opts ValueUnmarshalOpts) error {
+	return NewStringValue(string(v)).UnmarshalTo(out, opts)
+}
+
+func (v textValue) String() string {
+	return string(v)
+}
+
+func TestDecoderInterpolation(t *testing.T) {
+	t.Setenv("MARSHALER_TEST_HOME", "/home/test")
+	type target struct {
+		Host    string   `kv:"host"`
+		BaseURL string   `kv:"base_url"`
+		Data    string   `kv:"data"`
+		Cache   string   `kv:"cache" default:"${env:MARSHALER_TEST_HOME}/.cache"`
+		Price   string   `kv:"price"`
+		Mirrors []string `kv:"mirrors,indexed"`
+	}
+	kv := MapKV{
+		"app/host":      "example.com",
+		"app/scheme":    "https",
+		"app/base_url":  "${scheme}://${host}/api",
+		"app/data":      "${env:MARSHALER_TEST_HOME}/data",
+		"app/price":     "$${price} is literal",
+		"app/mirrors/0": "${base_url}/mirror",
+	}
+	dec, err := NewDecoder(kv, WithPrefix("app"), WithInterpolation(), WithStrict())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var cfg target
+	if err := dec.Decode(&cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := target{
+		Host:    "example.com",
+		BaseURL: "https://example.com/api",
+		Data:    "/home/test/data",
+		Cache:   "/home/test/.cache",
+		Price:   "${price} is literal",
+		Mirrors: []string{"https://example.com/api/mirror"},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, cfg)
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		var cfg target
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Data != "" {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+		cfg = target{}
+		dec, err := NewDecoder(kv, WithPrefix("app"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := dec.Decode(&cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.BaseURL != "${scheme}://${host}/api" {
+			t.Fatalf("expected %q, got %q", "${scheme}://${host}/api", cfg.BaseURL)
+		}
+	})
+	t.Run("NonStringValue", func(t *testing.T) {
+		kv := textKV{"host": "example.com", "url": "https://${host}/api", "port": "80"}
+		dec, err := NewDecoder(kv, WithInterpolation())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg struct {
+			URL  string `kv:"url"`
+			Port int    `kv:"port"`
+		}
+		if err := dec.Decode(&cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.URL != "https://example.com/api" || cfg.Port != 80 {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		tests := map[string]struct {
+			value  string
+			expect string
+		}{
+			"Cycle":        {"${b}", `reference cycle a -> b -> a`},
+			"Missing":      {"${missing}", `key "missing" is missing`},
+			"Env":          {"${env:MARSHALER_TEST_UNSET}", "environment variable is not set"},
+			"Unterminated": {"${b", "unterminated reference"},
+			"Empty":        {"${}", "empty reference"},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				kv := MapKV{"a": tc.value, "b": "${a}"}
+				dec, err := NewDecoder(kv, WithInterpolation())
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				var cfg struct {
+					A string `kv:"a"`
+				}
+				err = dec.Decode(&cfg)
+				var derr *DecodeError
+				if !errors.As(err, &derr) || !errors.Is(err, ErrInterpolation) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if derr.Key != "a" || derr.Value != tc.value || !strings.Contains(err.Error(), tc.expect) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			})
+		}
+	})
+}
+
+func TestDecoderResolvers(t *testing.T) {
+	t.Setenv("MARSHALER_TEST_PASS", "env-secret")
+	secretFile := filepath.Join(t.TempDir(), "db_pass")
+	if err := os.WriteFile(secretFile, []byte("file-secret\n"), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	type target struct {
+		File    string   `kv:"file"`
+		Env     string   `kv:"env"`
+		Base64  string   `kv:"base64"`
+		Plain   string   `kv:"plain"`
+		Unknown string   `kv:"unknown"`
+		Tokens  []string `kv:"tokens,indexed"`
+	}
+	kv := MapKV{
+		"file":     "file://" + secretFile,
+		"env":      "env://MARSHALER_TEST_PASS",
+		"base64":   "base64:c2VjcmV0",
+		"plain":    "plain",
+		"unknown":  "https://example.com",
+		"tokens/0": "env://MARSHALER_TEST_PASS",
+	}
+	opts := []DecoderOption{
+		WithResolver("file", FileResolver()),
+		WithResolver("env", EnvResolver()),
+		WithResolver("base64", Base64Resolver()),
+	}
+	dec, err := NewDecoder(kv, opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var cfg target
+	if err := dec.Decode(&cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := target{
+		File:    "file-secret",
+		Env:     "env-secret",
+		Base64:  "secret",
+		Plain:   "plain",
+		Unknown: "https://example.com",
+		Tokens:  []string{"env-secret"},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, cfg)
+	}
+
+	t.Run("NonStringValue", func(t *testing.T) {
+		dec, err := NewDecoder(textKV{"pass": "env://MARSHALER_TEST_PASS"}, opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg struct {
+			Pass string `kv:"pass"`
+		}
+		if err := dec.Decode(&cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Pass != "env-secret" {
+			t.Fatalf("expected %q, got %q", "env-secret", cfg.Pass)
+		}
+	})
+	t.Run("Interpolated", func(t *testing.T) {
+		kv := MapKV{"dir": filepath.Dir(secretFile), "pass": "file://${dir}/db_pass"}
+		dec, err := NewDecoder(kv, WithInterpolation(), WithResolver("file", FileResolver()))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg struct {
+			Pass string `kv:"pass"`
+		}
+		if err := dec.Decode(&cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Pass != "file-secret" {
+			t.Fatalf("expected %q, got %q", "file-secret", cfg.Pass)
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		kv := MapKV{"missing": "env://MARSHALER_TEST_UNSET", "port": "base64:YWJj"}
+		dec, err := NewDecoder(kv, append(opts, WithCollectErrors())...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var cfg struct {
+			Missing string `kv:"missing"`
+			Port    int    `kv:"port"`
+		}
+		err = dec.Decode(&cfg)
+		if !errors.Is(err, ErrResolve) || !errors.Is(err, ErrParse) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Contains(err.Error(), "abc") {
+			t.Fatalf("resolved value is not redacted: %v", err)
+		}
+	})
+	t.Run("Options", func(t *testing.T) {
+		if _, err := NewDecoder(kv, WithResolver("", EnvResolver())); !errors.Is(err, ErrEmptyScheme) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := NewDecoder(kv, WithResolver("env", nil)); !errors.Is(err, ErrNilResolver) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -58,6 +58,8 @@ Example:
 	}
 
 There is also a [Scanner] interface that can be implemented by a target type to
-provide custom unmarshaling logic.
+provide custom unmarshaling logic. Types implementing encoding.TextUnmarshaler,
+encoding.BinaryUnmarshaler or json.Unmarshaler (e.g. netip.Addr or slog.Level)
+are supported as well.
 */
 package marshaler
@@ -0,0 +1,245 @@
+package marshaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encoder encodes values and writes them to a key-value storage.
+//
+// It uses the same struct tags and key layout as [Decoder], so the data
+// written by Encoder could be decoded back by Decoder configured with the
+// same options.
+type Encoder struct {
+	kv     KVWriter
+	config decoderConfig
+	keys   keyBuilder
+	plans  *planCache
+}
+
+// NewEncoder returns a new encoder that writes to kv.
+//
+// It accepts the same options as [NewDecoder].
+func NewEncoder(kv KVWriter, opts ...DecoderOption) (*Encoder, error) {
+	cfg, err := newDecoderConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	keys := newKeyBuilder(cfg)
+	return &Encoder{kv: kv, config: cfg, keys: keys, plans: newPlanCache(cfg, keys)}, nil
+}
+
+// Encode encodes v and writes it to the key-value storage.
+func (e *Encoder) Encode(v any) error {
+	return e.EncodeContext(context.Background(), v)
+}
+
+// EncodeContext encodes v and writes it to the key-value storage.
+// It uses the provided context for the deadline and cancellation.
+//
+// The v must be a struct or a non-nil pointer to a struct.
+func (e *Encoder) EncodeContext(ctx context.Context, v any) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return fmt.Errorf("encode source must be a non-nil pointer")
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return fmt.Errorf("encode source must be a struct or a pointer to a struct")
+	}
+	if !val.CanAddr() {
+		// make a copy to be able to check pointer receiver methods.
+		cp := reflect.New(val.Type()).Elem()
+		cp.Set(val)
+		val = cp
+	}
+
+	plan, err := e.plans.get(val.Type())
+	if err != nil {
+		return err
+	}
+	return e.encodeStruct(ctx, location{}, plan.fields, val)
+}
+
+// encodeStruct encodes struct fields by plan relative to the base location.
+func (e *Encoder) encodeStruct(ctx context.Context, base location, fields []fieldPlan, val reflect.Value) error {
+	for i := range fields {
+		if err := e.encodeField(ctx, val.Field(fields[i].index), &fields[i], base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) encodeField(ctx context.Context, f reflect.Value, fp *fieldPlan, base location) error {
+	loc := base.at(e.keys, fp)
+
+	if f.Kind() == reflect.Ptr && f.IsNil() {
+		if fp.kind == structFieldKind {
+			return nil // nothing to write for nil nested struct
+		}
+		return e.delete(ctx, loc)
+	}
+
+	switch fp.kind {
+	case structFieldKind:
+		if f.Kind() == reflect.Ptr {
+			f = f.Elem()
+		}
+		if fp.recursive {
+			plan, err := e.plans.get(f.Type())
+			if err != nil {
+				return err
+			}
+			return e.encodeStruct(ctx, loc, plan.fields, f)
+		}
+		return e.encodeStruct(ctx, base, fp.fields, f)
+	case mapField:
+		return e.encodeMap(ctx, f, loc, fp.opts)
+	case indexedField:
+		return e.encodeIndexed(ctx, f, loc, fp.opts)
+	}
+
+	// missing keys are decoded as defaults, so zero values
+	// of fields with defaults are written.
+	if fp.spec.omitempty && f.IsZero() && !fp.spec.hasDef {
+		return e.delete(ctx, loc)
+	}
+	if f.Kind() == reflect.Slice && f.IsNil() && !fp.spec.hasDef {
+		return e.delete(ctx, loc) // nil slice is a missing key, empty slice is empty value
+	}
+	return e.put(ctx, loc, f, fp.opts)
+}
+
+func (e *Encoder) encodeMap(ctx context.Context, f reflect.Value, loc location, opts ValueUnmarshalOpts) error {
+	t := f.Type()
+	if t.Key().Kind() != reflect.String {
+		return fmt.Errorf("encode field %s: %w: map key type %s", loc.field, ErrUnsupportedType, t.Key())
+	}
+
+	keys := f.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	children := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		children[e.keys.escape(k.String())] = struct{}{}
+	}
+	if err := e.deleteStale(ctx, loc, children); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		// copy map value to make it addressable.
+		elem := reflect.New(t.Elem()).Elem()
+		elem.Set(f.MapIndex(k))
+		name := k.String()
+		if err := e.encodeElem(ctx, elem, loc.elem(e.keys, e.keys.escape(name), name), opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) encodeIndexed(ctx context.Context, f reflect.Value, loc location, opts ValueUnmarshalOpts) error {
+	children := make(map[string]struct{}, f.Len())
+	for i := 0; i < f.Len(); i++ {
+		children[strconv.Itoa(i)] = struct{}{}
+	}
+	if err := e.deleteStale(ctx, loc, children); err != nil {
+		return err
+	}
+	for i := 0; i < f.Len(); i++ {
+		idx := strconv.Itoa(i)
+		if err := e.encodeElem(ctx, f.Index(i), loc.elem(e.keys, idx, idx), opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeElem encodes map, slice or array element
+// using the options of the field.
+func (e *Encoder) encodeElem(ctx context.Context, v reflect.Value, loc location, opts ValueUnmarshalOpts) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+	if isStructType(v.Type()) {
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		plan, err := e.plans.get(v.Type())
+		if err != nil {
+			return err
+		}
+		return e.encodeStruct(ctx, loc, plan.fields, v)
+	}
+	return e.put(ctx, loc, v, opts)
+}
+
+// deleteStale deletes keys of map entries or slice elements under
+// the location which are not in children, e.g. elements of a longer
+// slice written before. It does nothing if the storage doesn't
+// support listing.
+func (e *Encoder) deleteStale(ctx context.Context, loc location, children map[string]struct{}) error {
+	lister, ok := e.kv.(Lister)
+	if !ok {
+		return nil
+	}
+	prefix := e.keys.listPrefix(loc.path)
+	keys, err := lister.List(ctx, prefix)
+	if errors.Is(err, ErrNotSupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("encode field %s: list keys %q: %w", loc.field, prefix, err)
+	}
+	for _, key := range keys {
+		child, _, _ := strings.Cut(strings.TrimPrefix(key, prefix), e.keys.sep)
+		if _, ok := children[child]; ok {
+			continue
+		}
+		if err := e.kv.Delete(ctx, key); err != nil {
+			return fmt.Errorf("encode field %s: delete key %q: %w", loc.field, key, err)
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) put(ctx context.Context, loc location, v reflect.Value, opts ValueUnmarshalOpts) error {
+	value, err := formatValue(fieldInterface(v), opts)
+	if err != nil {
+		return fmt.Errorf("encode field %s: format value of %q: %w", loc.field, loc.key, err)
+	}
+	if err := e.kv.Put(ctx, loc.key, value); err != nil {
+		return fmt.Errorf("encode field %s: put key %q: %w", loc.field, loc.key, err)
+	}
+	return nil
+}
+
+func (e *Encoder) delete(ctx context.Context, loc location) error {
+	if err := e.kv.Delete(ctx, loc.key); err != nil {
+		return fmt.Errorf("encode field %s: delete key %q: %w", loc.field, loc.key, err)
+	}
+	return nil
+}
+
+// MarshalContext encodes v and writes it to the key-value storage
+// using the provided context and default encoder configuration.
+func MarshalContext(ctx context.Context, kv KVWriter, v any) error {
+	enc, err := NewEncoder(kv)
+	if err != nil {
+		return err
+	}
+	return enc.EncodeContext(ctx, v)
+}
+
+// Marshal encodes v and writes it to the key-value storage.
+// See [MarshalContext] for more details.
+func Marshal(kv KVWriter, v any) error {
+	return MarshalContext(context.Background(), kv, v)
+}
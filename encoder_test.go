@@ -0,0 +1,254 @@
+package marshaler
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type textScanner struct {
+	value string
+}
+
+func (s *textScanner) Scan(v any) error {
+	if v, ok := v.(string); ok {
+		s.value = v
+		return nil
+	}
+	return errors.New("unexpected type")
+}
+
+func (s textScanner) String() string {
+	return s.value
+}
+
+type encoderTarget struct {
+	Foo    string       `kv:"foo"`
+	Bar    int          `kv:"bar"`
+	Opt    *bool        `kv:"opt"`
+	Empty  string       `kv:"empty,omitempty"`
+	Params []string     `kv:"params"`
+	Skip   string       // no tag
+	Time   time.Time    `kv:"time"`
+	Custom *textScanner `kv:"custom"`
+	Nested struct {
+		Dur   time.Duration `kv:"dur"`
+		Float float64       `kv:"float"`
+		Deep  *struct {
+			Num uint16 `kv:"num"`
+		} `kv:"deep"`
+	} `kv:"nested"`
+	Nil *struct {
+		Num int `kv:"num"`
+	} `kv:"nil"`
+}
+
+func TestEncoder(t *testing.T) {
+	t.Run("Encode", func(t *testing.T) {
+		opt := true
+		src := encoderTarget{
+			Foo:    "foo",
+			Bar:    42,
+			Opt:    &opt,
+			Params: []string{"a", "b"},
+			Skip:   "skip",
+			Time:   time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			Custom: &textScanner{value: "custom"},
+		}
+		src.Nested.Dur = time.Second
+		src.Nested.Float = 0.5
+		src.Nested.Deep = &struct {
+			Num uint16 `kv:"num"`
+		}{Num: 7}
+
+		kv := MapKV{"empty": "stale"}
+		if err := Marshal(kv, src); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := MapKV{
+			"foo":             "foo",
+			"bar":             "42",
+			"opt":             "true",
+			"params":          "a,b",
+			"time":            "2021-01-01T00:00:00Z",
+			"custom":          "custom",
+			"nested/dur":      "1s",
+			"nested/float":    "0.5",
+			"nested/deep/num": "7",
+		}
+		if len(kv) != len(expected) {
+			t.Fatalf("expected %d keys, got %d: %v", len(expected), len(kv), kv)
+		}
+		for k, v := range expected {
+			if kv[k] != v {
+				t.Fatalf("expected %q for key %q, got %q", v, k, kv[k])
+			}
+		}
+	})
+	t.Run("RoundTrip", func(t *testing.T) {
+		opt := false
+		src := encoderTarget{
+			Foo:    "foo",
+			Bar:    -1,
+			Opt:    &opt,
+			Params: []string{"x", "y", "z"},
+			Time:   time.Date(2021, 1, 1, 10, 20, 30, 400, time.UTC),
+			Custom: &textScanner{value: "custom"},
+		}
+		src.Nested.Dur = time.Minute
+		src.Nested.Float = 1.25
+
+		kv := MapKV{}
+		enc, err := NewEncoder(kv, WithPrefix("app/"), WithSliceSeparator(";"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := enc.Encode(&src); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		dec, err := NewDecoder(kv, WithPrefix("app/"), WithSliceSeparator(";"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var dst encoderTarget
+		if err := dec.Decode(&dst); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if dst.Foo != src.Foo || dst.Bar != src.Bar || *dst.Opt != *src.Opt {
+			t.Fatalf("expected %+v, got %+v", src, dst)
+		}
+		if len(dst.Params) != 3 || dst.Params[2] != "z" {
+			t.Fatalf("expected %v, got %v", src.Params, dst.Params)
+		}
+		if !dst.Time.Equal(src.Time) {
+			t.Fatalf("expected %v, got %v", src.Time, dst.Time)
+		}
+		if dst.Custom.value != src.Custom.value {
+			t.Fatalf("expected %q, got %q", src.Custom.value, dst.Custom.value)
+		}
+		if dst.Nested.Dur != src.Nested.Dur || dst.Nested.Float != src.Nested.Float {
+			t.Fatalf("expected %+v, got %+v", src.Nested, dst.Nested)
+		}
+	})
+	t.Run("Integers", func(t *testing.T) {
+		type ints struct {
+			I8  int8    `kv:"i8"`
+			I16 int16   `kv:"i16"`
+			I32 int32   `kv:"i32"`
+			I64 int64   `kv:"i64"`
+			U   uint    `kv:"u"`
+			U8  uint8   `kv:"u8"`
+			U16 uint16  `kv:"u16"`
+			U32 uint32  `kv:"u32"`
+			U64 uint64  `kv:"u64"`
+			Arr []uint8 `kv:"arr"`
+		}
+		for _, src := range []ints{
+			{math.MaxInt8, math.MaxInt16, math.MaxInt32, math.MaxInt64,
+				math.MaxUint, math.MaxUint8, math.MaxUint16, math.MaxUint32, math.MaxUint64, []uint8{200, 255}},
+			{I8: math.MinInt8, I16: math.MinInt16, I32: math.MinInt32, I64: math.MinInt64, Arr: []uint8{0}},
+		} {
+			kv := MapKV{}
+			if err := Marshal(kv, src); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var dst ints
+			if err := Unmarshal(kv, &dst); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(dst, src) {
+				t.Fatalf("expected %+v, got %+v", src, dst)
+			}
+		}
+	})
+	t.Run("EmptySlices", func(t *testing.T) {
+		type slices struct {
+			Nil   []string `kv:"nil"`
+			Empty []string `kv:"empty"`
+		}
+		kv := MapKV{"nil": "stale"}
+		if err := Marshal(kv, slices{Empty: []string{}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := kv["nil"]; ok {
+			t.Fatalf("unexpected key of nil slice: %v", kv)
+		}
+		var dst slices
+		if err := Unmarshal(kv, &dst); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if dst.Nil != nil || dst.Empty == nil || len(dst.Empty) != 0 {
+			t.Fatalf("unexpected slices: %q, %q", dst.Nil, dst.Empty)
+		}
+	})
+	t.Run("Defaults", func(t *testing.T) {
+		// zero values are written to not be decoded as defaults.
+		type target struct {
+			Port int      `kv:"port,omitempty,default=8080"`
+			Tags []string `kv:"tags,default=a,b"`
+			Host string   `kv:"host,omitempty"`
+		}
+		kv := MapKV{"host": "stale"}
+		if err := Marshal(kv, target{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := kv["host"]; ok {
+			t.Fatalf("unexpected key of omitempty field: %v", kv)
+		}
+		var dst target
+		if err := Unmarshal(kv, &dst); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if dst.Port != 0 || len(dst.Tags) != 0 {
+			t.Fatalf("unexpected values: %+v", dst)
+		}
+	})
+	t.Run("StaleElements", func(t *testing.T) {
+		type backend struct {
+			Host string `kv:"host"`
+		}
+		type target struct {
+			Backends  []backend         `kv:"backends"`
+			Upstreams map[string]string `kv:"upstreams"`
+		}
+		kv := MapKV{}
+		long := target{
+			Backends:  []backend{{"a"}, {"b"}, {"c"}},
+			Upstreams: map[string]string{"a": "a.local", "b": "b.local"},
+		}
+		if err := Marshal(kv, long); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		short := target{
+			Backends:  []backend{{"x"}},
+			Upstreams: map[string]string{"b": "b.remote"},
+		}
+		if err := Marshal(kv, short); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := MapKV{"backends/0/host": "x", "upstreams/b": "b.remote"}
+		if !reflect.DeepEqual(kv, expected) {
+			t.Fatalf("expected %v, got %v", expected, kv)
+		}
+		var dst target
+		if err := Unmarshal(kv, &dst); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(dst, short) {
+			t.Fatalf("expected %+v, got %+v", short, dst)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		if err := Marshal(MapKV{}, 42); err == nil {
+			t.Fatalf("expected error, got nil")
+		}
+		src := struct {
+			Ch chan int `kv:"ch"`
+		}{Ch: make(chan int)}
+		if err := Marshal(MapKV{}, &src); err == nil {
+			t.Fatalf("expected error, got nil")
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package env
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/g4s8/go-marshaler"
+)
+
+type config struct {
+	prefix   string
+	sep      string
+	fold     bool
+	vars     map[string]string
+	sliceSep string
+}
+
+// Option configures environment variables mapping and decoding.
+type Option func(*config)
+
+// WithPrefix sets the prefix of variable names, e.g. `APP` for
+// `APP_LOGGER_LEVEL`. The prefix is joined with names by the separator.
+func WithPrefix(prefix string) Option {
+	return func(c *config) {
+		c.prefix = prefix
+	}
+}
+
+// WithSeparator sets the separator of variable name segments
+// which replaces the key separator.
+//
+// Default is "_".
+func WithSeparator(sep string) Option {
+	return func(c *config) {
+		c.sep = sep
+	}
+}
+
+// WithCaseFolding enables or disables upper-casing of variable names,
+// listed names are lower-cased if it's enabled.
+//
+// Default is true.
+func WithCaseFolding(fold bool) Option {
+	return func(c *config) {
+		c.fold = fold
+	}
+}
+
+// WithVars sets variables to use instead of the process environment,
+// e.g. for tests.
+func WithVars(vars map[string]string) Option {
+	return func(c *config) {
+		c.vars = vars
+	}
+}
+
+// WithSliceSeparator sets the separator for slice values.
+func WithSliceSeparator(separator string) Option {
+	return func(c *config) {
+		c.sliceSep = separator
+	}
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{sep: "_", fold: true}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.sep == "" {
+		cfg.sep = "_"
+	}
+	return cfg
+}
+
+func (cfg *config) kv() *KV {
+	return &KV{prefix: cfg.prefix, sep: cfg.sep, fold: cfg.fold, vars: cfg.vars}
+}
+
+func (cfg *config) options() []marshaler.DecoderOption {
+	decOpts := make([]marshaler.DecoderOption, 0)
+	decOpts = append(decOpts, marshaler.WithSeparator(keySep))
+	decOpts = append(decOpts, marshaler.WithTag("env"))
+	if cfg.sliceSep != "" {
+		decOpts = append(decOpts, marshaler.WithSliceSeparator(cfg.sliceSep))
+	}
+	return decOpts
+}
+
+// Decoder decodes environment variables into structs using `env` tags.
+type Decoder struct {
+	dec *marshaler.Decoder
+}
+
+// NewDecoder creates a new environment variables decoder.
+func NewDecoder(opts ...Option) (*Decoder, error) {
+	cfg := newConfig(opts)
+	dec, err := marshaler.NewDecoder(cfg.kv(), cfg.options()...)
+	if err != nil {
+		return nil, fmt.Errorf("create decoder: %w", err)
+	}
+	return &Decoder{dec: dec}, nil
+}
+
+func (d *Decoder) Decode(v any) error {
+	return d.dec.Decode(v)
+}
+
+func (d *Decoder) DecodeContext(ctx context.Context, v any) error {
+	return d.dec.DecodeContext(ctx, v)
+}
+
+// Unmarshal decodes environment variables into v.
+func Unmarshal(v any, opts ...Option) error {
+	return UnmarshalContext(context.Background(), v, opts...)
+}
+
+// UnmarshalContext decodes environment variables into v
+// using the provided context.
+func UnmarshalContext(ctx context.Context, v any, opts ...Option) error {
+	dec, err := NewDecoder(opts...)
+	if err != nil {
+		return fmt.Errorf("create decoder: %w", err)
+	}
+	return dec.DecodeContext(ctx, v)
+}
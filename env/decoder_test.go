@@ -0,0 +1,129 @@
+package env
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/g4s8/go-marshaler"
+)
+
+type testTarget struct {
+	Host   string `env:"host"`
+	Port   int    `env:"port"`
+	Logger struct {
+		Level string `env:"level"`
+	} `env:"logger"`
+	Upstreams map[string]string `env:"upstreams"`
+	Backends  []struct {
+		Host string `env:"host"`
+	} `env:"backends"`
+	Params []string `env:"params"`
+}
+
+func TestDecoder(t *testing.T) {
+	vars := map[string]string{
+		"APP_HOST":              "localhost",
+		"APP_PORT":              "8080",
+		"APP_LOGGER_LEVEL":      "debug",
+		"APP_UPSTREAMS_A":       "a.local",
+		"APP_UPSTREAMS_B":       "b.local",
+		"APP_BACKENDS_0_HOST":   "b0",
+		"APP_BACKENDS_1_HOST":   "b1",
+		"APP_PARAMS":            "x;y",
+		"OTHER_HOST":            "unexpected",
+		"APP_LOGGER_UNEXPECTED": "ignored",
+	}
+	dec, err := NewDecoder(WithPrefix("APP"), WithVars(vars), WithSliceSeparator(";"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var cfg testTarget
+	if err := dec.Decode(&cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Host != "localhost" || cfg.Port != 8080 || cfg.Logger.Level != "debug" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Upstreams, map[string]string{"a": "a.local", "b": "b.local"}) {
+		t.Fatalf("unexpected upstreams: %v", cfg.Upstreams)
+	}
+	if len(cfg.Backends) != 2 || cfg.Backends[0].Host != "b0" || cfg.Backends[1].Host != "b1" {
+		t.Fatalf("unexpected backends: %+v", cfg.Backends)
+	}
+	if !reflect.DeepEqual(cfg.Params, []string{"x", "y"}) {
+		t.Fatalf("unexpected params: %v", cfg.Params)
+	}
+}
+
+func TestKV(t *testing.T) {
+	t.Run("Separator", func(t *testing.T) {
+		vars := map[string]string{
+			"APP__SERVER__READ_TIMEOUT": "1s",
+			"APP__SERVER__MAX_CONNS":    "10",
+		}
+		kv := NewKV(WithPrefix("APP"), WithSeparator("__"), WithVars(vars))
+		var cfg struct {
+			Server map[string]string `kv:"server"`
+		}
+		if err := marshaler.Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := map[string]string{"read_timeout": "1s", "max_conns": "10"}
+		if !reflect.DeepEqual(cfg.Server, expected) {
+			t.Fatalf("expected %v, got %v", expected, cfg.Server)
+		}
+	})
+	t.Run("NoFolding", func(t *testing.T) {
+		kv := NewKV(WithCaseFolding(false), WithVars(map[string]string{"Logger_Level": "info"}))
+		val, err := kv.Get(context.Background(), "Logger/Level")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if val == marshaler.NullValue {
+			t.Fatalf("expected value of %q", "Logger_Level")
+		}
+		keys, err := kv.List(context.Background(), "Logger/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actual := strings.Join(keys, ","); actual != "Logger/Level" {
+			t.Fatalf("expected %q, got %q", "Logger/Level", actual)
+		}
+	})
+	t.Run("Environ", func(t *testing.T) {
+		t.Setenv("MARSHALER_TEST_TIMEOUT", "5s")
+		var cfg struct {
+			Timeout time.Duration `env:"timeout"`
+		}
+		if err := Unmarshal(&cfg, WithPrefix("MARSHALER_TEST")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Timeout != 5*time.Second {
+			t.Fatalf("expected %v, got %v", 5*time.Second, cfg.Timeout)
+		}
+		keys, err := NewKV(WithPrefix("MARSHALER_TEST")).List(context.Background(), "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actual := strings.Join(keys, ","); actual != "timeout" {
+			t.Fatalf("expected %q, got %q", "timeout", actual)
+		}
+	})
+	t.Run("Layered", func(t *testing.T) {
+		env := NewKV(WithPrefix("APP"), WithVars(map[string]string{"APP_LOGGER_LEVEL": "debug"}))
+		defaults := marshaler.MapKV{"logger/level": "info", "port": "80"}
+		var cfg struct {
+			Level string `kv:"logger/level"`
+			Port  int    `kv:"port"`
+		}
+		if err := marshaler.Unmarshal(marshaler.Layered(env, defaults), &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Level != "debug" || cfg.Port != 80 {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+}
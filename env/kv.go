@@ -0,0 +1,111 @@
+package env
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/g4s8/go-marshaler"
+)
+
+var (
+	_ marshaler.KV     = (*KV)(nil)
+	_ marshaler.Lister = (*KV)(nil)
+)
+
+// keySep is a separator of marshaler keys, env variable names
+// use the configured separator instead.
+const keySep = "/"
+
+// KV is a key-value storage over environment variables.
+//
+// Keys like `logger/level` are mapped to variable names like
+// `APP_LOGGER_LEVEL`: the key separator is replaced with the variable
+// separator, the name is upper-cased and prefixed with the prefix.
+// Listed variable names are mapped back to keys, so keys should not
+// contain the variable separator to be listed correctly, e.g. use
+// `__` separator for `read_timeout` keys.
+type KV struct {
+	prefix string
+	sep    string
+	fold   bool
+	vars   map[string]string
+}
+
+// NewKV creates a new environment variables KV.
+func NewKV(opts ...Option) *KV {
+	cfg := newConfig(opts)
+	return cfg.kv()
+}
+
+func (kv *KV) Get(ctx context.Context, key string) (marshaler.Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	val, ok := kv.lookup(kv.name(key))
+	if !ok {
+		return marshaler.NullValue, nil
+	}
+	return marshaler.NewStringValue(val), nil
+}
+
+func (kv *KV) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	namePrefix := kv.name(prefix)
+	keys := make([]string, 0)
+	for _, name := range kv.names() {
+		if rest, ok := strings.CutPrefix(name, namePrefix); ok {
+			keys = append(keys, prefix+kv.key(rest))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// name returns the variable name of the key.
+func (kv *KV) name(key string) string {
+	name := strings.ReplaceAll(key, keySep, kv.sep)
+	if kv.fold {
+		name = strings.ToUpper(name)
+	}
+	if kv.prefix != "" {
+		name = kv.prefix + kv.sep + name
+	}
+	return name
+}
+
+// key returns the key of the variable name without the prefix.
+func (kv *KV) key(name string) string {
+	if kv.fold {
+		name = strings.ToLower(name)
+	}
+	return strings.ReplaceAll(name, kv.sep, keySep)
+}
+
+func (kv *KV) lookup(name string) (string, bool) {
+	if kv.vars != nil {
+		val, ok := kv.vars[name]
+		return val, ok
+	}
+	return os.LookupEnv(name)
+}
+
+func (kv *KV) names() []string {
+	if kv.vars != nil {
+		names := make([]string, 0, len(kv.vars))
+		for name := range kv.vars {
+			names = append(names, name)
+		}
+		return names
+	}
+	environ := os.Environ()
+	names := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		names = append(names, name)
+	}
+	return names
+}
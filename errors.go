@@ -0,0 +1,120 @@
+package marshaler
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Decode error kinds, they could be checked with errors.Is
+// against [DecodeError] and [MissingKeysError].
+var (
+	// ErrMissingKey is a kind of error for missing required keys.
+	ErrMissingKey = errors.New("missing key")
+	// ErrParse is a kind of error for values which can't be converted
+	// to the target type.
+	ErrParse = errors.New("parse error")
+	// ErrUnsupportedType is a kind of error for target types which
+	// are not supported by the decoder or the value.
+	ErrUnsupportedType = errors.New("unsupported type")
+	// ErrBackend is a kind of error for key-value storage failures.
+	ErrBackend = errors.New("backend error")
+	// ErrUnknownKey is a kind of error for keys not consumed by
+	// any field in strict mode.
+	ErrUnknownKey = errors.New("unknown key")
+	// ErrInterpolation is a kind of error for values with references
+	// which can't be expanded, see [WithInterpolation].
+	ErrInterpolation = errors.New("interpolation error")
+	// ErrResolve is a kind of error for values which can't be resolved
+	// by the resolver, see [WithResolver].
+	ErrResolve = errors.New("resolve error")
+)
+
+// DecodeError is an error of decoding a single field.
+//
+// It unwraps to both the error kind (e.g. [ErrParse]) and the cause.
+type DecodeError struct {
+	// Field is a Go path of the field, e.g. `Logger.Level`,
+	// `Upstreams[a].Host` or `Ports[0]`.
+	Field string
+	// Key is a storage key of the field.
+	Key string
+	// Value is a raw storage value if it's available and not redacted.
+	Value string
+	// Redacted is true if the value is hidden from the error,
+	// see [WithRedactedValues] and `secret` tag option.
+	Redacted bool
+	// Type is a target type.
+	Type reflect.Type
+	// Kind is one of ErrMissingKey, ErrParse, ErrUnsupportedType,
+	// ErrBackend, ErrInterpolation or ErrResolve.
+	Kind error
+	// Err is the cause of the error.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "decode field %s", e.Field)
+	if e.Key != "" {
+		fmt.Fprintf(&sb, " from key %q", e.Key)
+	}
+	if e.Value != "" {
+		fmt.Fprintf(&sb, " value %q", e.Value)
+	}
+	if e.Type != nil {
+		fmt.Fprintf(&sb, " to %s", e.Type)
+	}
+	// the cause could contain the value, so it's hidden if redacted.
+	cause := e.Err
+	if cause == nil || e.Redacted {
+		cause = e.Kind
+	}
+	fmt.Fprintf(&sb, ": %v", cause)
+	return sb.String()
+}
+
+func (e *DecodeError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// MissingKeysError is returned by [Decoder] if required keys are missing.
+//
+// Fields are required if they have `required` tag option,
+// or if the decoder is configured with [WithRequireAll].
+// The decoder doesn't stop on the first missing key, so the error
+// contains all missing keys.
+type MissingKeysError struct {
+	// Keys is a list of missing keys in decoding order.
+	Keys []string
+}
+
+func (e *MissingKeysError) Error() string {
+	return "missing required keys: " + strings.Join(e.Keys, ", ")
+}
+
+// Is reports that the error is [ErrMissingKey].
+func (e *MissingKeysError) Is(target error) bool {
+	return target == ErrMissingKey
+}
+
+// UnknownKeysError is returned by [Decoder] in strict mode if the storage
+// contains keys under the prefix which were not consumed by any field,
+// see [WithStrict].
+type UnknownKeysError struct {
+	// Keys is a list of unknown keys in listing order.
+	Keys []string
+}
+
+func (e *UnknownKeysError) Error() string {
+	return "unknown keys: " + strings.Join(e.Keys, ", ")
+}
+
+// Is reports that the error is [ErrUnknownKey].
+func (e *UnknownKeysError) Is(target error) bool {
+	return target == ErrUnknownKey
+}
@@ -0,0 +1,262 @@
+package file
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ParseError is an error of parsing a line-based document,
+// e.g. dotenv or properties file.
+type ParseError struct {
+	// Line is the 1-based number of the line where the error occurs.
+	Line int
+	// Msg describes the error.
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// flatPair is a key-value pair of a line-based document,
+// the key is split to key segments.
+type flatPair struct {
+	path  []string
+	value string
+}
+
+// dotenvSep is a separator of nested key segments in dotenv files,
+// e.g. `LOGGER__LEVEL` for `logger/level` key.
+const dotenvSep = "__"
+
+// parseDotenv parses dotenv document:
+//   - `KEY=value` pairs with optional `export` prefix;
+//   - `#` comments, inline comments of unquoted values start with ` #`;
+//   - single-quoted literal values and double-quoted values with
+//     `\n`, `\r`, `\t`, `\"`, `\\` and `\$` escapes, quoted values
+//     could span multiple lines.
+//
+// Keys are lower-cased and split by `__`.
+func parseDotenv(data []byte) ([]flatPair, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	var pairs []flatPair
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			line = strings.TrimSpace(rest)
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, &ParseError{Line: lineNo, Msg: "expected KEY=value"}
+		}
+		key = strings.TrimSpace(key)
+		if !isDotenvKey(key) {
+			return nil, &ParseError{Line: lineNo, Msg: fmt.Sprintf("invalid key %q", key)}
+		}
+		val = strings.TrimLeft(val, " \t")
+
+		var value string
+		if val != "" && (val[0] == '"' || val[0] == '\'') {
+			quote := val[0]
+			// the value could span multiple lines
+			text := val[1:]
+			for {
+				end := closingQuote(text, quote)
+				if end >= 0 {
+					rest := strings.TrimSpace(text[end+1:])
+					if rest != "" && !strings.HasPrefix(rest, "#") {
+						return nil, &ParseError{Line: i + 1, Msg: "unexpected characters after quoted value"}
+					}
+					text = text[:end]
+					break
+				}
+				if i+1 >= len(lines) {
+					return nil, &ParseError{Line: lineNo, Msg: "unterminated quoted value"}
+				}
+				i++
+				text += "\n" + lines[i]
+			}
+			if quote == '"' {
+				value = unescapeDotenv(text)
+			} else {
+				value = text
+			}
+		} else {
+			if j := strings.Index(val, " #"); j >= 0 {
+				val = val[:j]
+			} else if strings.HasPrefix(val, "#") {
+				val = ""
+			}
+			value = strings.TrimSpace(val)
+		}
+		path := strings.Split(strings.ToLower(key), dotenvSep)
+		pairs = append(pairs, flatPair{path: path, value: value})
+	}
+	return pairs, nil
+}
+
+func isDotenvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_' || c == '.' || c == '-':
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// closingQuote returns the index of the closing quote,
+// double quotes could be escaped by backslash.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+var dotenvUnescaper = strings.NewReplacer(
+	`\n`, "\n",
+	`\r`, "\r",
+	`\t`, "\t",
+	`\"`, `"`,
+	`\$`, `$`,
+	`\\`, `\`,
+)
+
+func unescapeDotenv(s string) string {
+	return dotenvUnescaper.Replace(s)
+}
+
+// parseProperties parses Java properties document:
+//   - `key=value`, `key: value` or `key value` pairs;
+//   - `#` and `!` comments;
+//   - line continuation by trailing backslash;
+//   - `\t`, `\n`, `\r`, `\f`, `\uXXXX` and `\c` escapes.
+//
+// Keys are split by `.`.
+func parseProperties(data []byte) ([]flatPair, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	var pairs []flatPair
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// join continuation lines
+		for endsWithBackslash(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		if endsWithBackslash(line) {
+			line = line[:len(line)-1]
+		}
+
+		keyEnd := len(line)
+		for j := 0; j < len(line); j++ {
+			if line[j] == '\\' {
+				j++
+				continue
+			}
+			if line[j] == '=' || line[j] == ':' || line[j] == ' ' || line[j] == '\t' || line[j] == '\f' {
+				keyEnd = j
+				break
+			}
+		}
+		rawKey, rest := line[:keyEnd], strings.TrimLeft(line[keyEnd:], " \t\f")
+		if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+			rest = strings.TrimLeft(rest[1:], " \t\f")
+		}
+		key, err := unescapeProperties(rawKey)
+		if err != nil {
+			return nil, &ParseError{Line: lineNo, Msg: err.Error()}
+		}
+		value, err := unescapeProperties(rest)
+		if err != nil {
+			return nil, &ParseError{Line: lineNo, Msg: err.Error()}
+		}
+		pairs = append(pairs, flatPair{path: strings.Split(key, "."), value: value})
+	}
+	return pairs, nil
+}
+
+// endsWithBackslash checks if the line ends with odd number of backslashes.
+func endsWithBackslash(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+func unescapeProperties(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'u':
+			r, err := parseUnicodeEscape(s[i-1:])
+			if err != nil {
+				return "", err
+			}
+			i += 4
+			if utf16.IsSurrogate(r) {
+				// surrogate pair, e.g. `\uD83D\uDE00`
+				low, err := parseUnicodeEscape(s[i+1:])
+				if err != nil {
+					return "", err
+				}
+				r = utf16.DecodeRune(r, low)
+				i += 6
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), nil
+}
+
+// parseUnicodeEscape parses `\uXXXX` escape at the start of s.
+func parseUnicodeEscape(s string) (rune, error) {
+	if len(s) < 6 || s[:2] != `\u` {
+		return 0, fmt.Errorf("malformed \\u escape")
+	}
+	r, err := strconv.ParseUint(s[2:6], 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("malformed \\u escape %q", s[:6])
+	}
+	return rune(r), nil
+}
@@ -0,0 +1,119 @@
+package file
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/g4s8/go-marshaler"
+)
+
+type flatTarget struct {
+	Host   string `kv:"host"`
+	Port   int    `kv:"port"`
+	Logger struct {
+		Level  string `kv:"level"`
+		Format string `kv:"format"`
+	} `kv:"logger"`
+	Motd     string   `kv:"motd"`
+	Password string   `kv:"password"`
+	Note     string   `kv:"note"`
+	Tags     []string `kv:"tags"`
+}
+
+func TestParseDotenv(t *testing.T) {
+	doc := `# comment
+HOST=localhost
+export PORT=8080
+LOGGER__LEVEL = info # inline comment
+LOGGER__FORMAT='json #not a comment'
+MOTD="line 1
+line 2\t\"quoted\" \$HOME"
+PASSWORD='p@ss\nword'
+NOTE=
+TAGS=a,b
+`
+	kv, err := Parse([]byte(doc), Dotenv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var cfg flatTarget
+	if err := marshaler.Unmarshal(kv, &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := flatTarget{
+		Host:     "localhost",
+		Port:     8080,
+		Motd:     "line 1\nline 2\t\"quoted\" $HOME",
+		Password: `p@ss\nword`,
+		Tags:     []string{"a", "b"},
+	}
+	expected.Logger.Level = "info"
+	expected.Logger.Format = "json #not a comment"
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, cfg)
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		for doc, line := range map[string]int{
+			"HOST=localhost\nPORT":                 2,
+			"HOST=localhost\n1HOST=x":              2,
+			"A=1\nMOTD=\"unterminated\nline\n":     2,
+			"A=1\n\nB='quoted' trailing":           3,
+			"A=1\nB=\"multi\nline\" trailing\nC=1": 3,
+		} {
+			_, err := Parse([]byte(doc), Dotenv)
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("unexpected error of %q: %v", doc, err)
+			}
+			if perr.Line != line {
+				t.Fatalf("expected error at line %d of %q, got %v", line, doc, err)
+			}
+		}
+	})
+}
+
+func TestParseProperties(t *testing.T) {
+	doc := `# comment
+! another comment
+host = localhost
+port: 8080
+logger.level info
+logger.format=json
+motd = line 1\n\
+       line 2 \u00e9\uD83D\uDE00
+password=p\=ss\:word\\
+note
+tags=a,\
+  b
+`
+	kv, err := Parse([]byte(doc), Properties)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var cfg flatTarget
+	if err := marshaler.Unmarshal(kv, &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := flatTarget{
+		Host:     "localhost",
+		Port:     8080,
+		Motd:     "line 1\nline 2 é😀",
+		Password: `p=ss:word\`,
+		Tags:     []string{"a", "b"},
+	}
+	expected.Logger.Level = "info"
+	expected.Logger.Format = "json"
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, cfg)
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := Parse([]byte("a=1\nb=\\u12"), Properties)
+		var perr *ParseError
+		if !errors.As(err, &perr) || perr.Line != 2 {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a structured document format.
+type Format string
+
+// Supported formats.
+const (
+	JSON Format = "json"
+	YAML Format = "yaml"
+	TOML Format = "toml"
+	// Dotenv is a `.env` file format, keys like `LOGGER__LEVEL`
+	// are mapped to `logger/level`.
+	Dotenv Format = "dotenv"
+	// Properties is a Java properties file format, keys like
+	// `logger.level` are mapped to `logger/level`.
+	Properties Format = "properties"
+)
+
+func (f Format) String() string {
+	return string(f)
+}
+
+// decode decodes the document to generic maps, slices and scalars.
+func (f Format) decode(data []byte) (any, error) {
+	var doc any
+	switch f {
+	case JSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		// keep numbers as is to avoid float64 precision loss
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+	case YAML:
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	case TOML:
+		var m map[string]any
+		if err := toml.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		doc = m
+	default:
+		return nil, fmt.Errorf("unsupported format %q", string(f))
+	}
+	return doc, nil
+}
+
+// decodeFlat decodes line-based document to key-value pairs,
+// it returns false if the format is not line-based.
+func (f Format) decodeFlat(data []byte) ([]flatPair, bool, error) {
+	var (
+		pairs []flatPair
+		err   error
+	)
+	switch f {
+	case Dotenv:
+		pairs, err = parseDotenv(data)
+	case Properties:
+		pairs, err = parseProperties(data)
+	default:
+		return nil, false, nil
+	}
+	return pairs, true, err
+}
+
+// formatOf returns the format of the file extension.
+func formatOf(ext string) (Format, error) {
+	switch strings.ToLower(ext) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".toml":
+		return TOML, nil
+	case ".env":
+		return Dotenv, nil
+	case ".properties":
+		return Properties, nil
+	}
+	return "", fmt.Errorf("unsupported file extension %q", ext)
+}
@@ -0,0 +1,11 @@
+module github.com/g4s8/go-marshaler/file
+
+go 1.22.0
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/g4s8/go-marshaler v0.0.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+replace github.com/g4s8/go-marshaler => ../
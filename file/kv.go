@@ -0,0 +1,165 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/g4s8/go-marshaler"
+)
+
+var (
+	_ marshaler.KV     = (*KV)(nil)
+	_ marshaler.Lister = (*KV)(nil)
+)
+
+// KV is an in-memory key-value storage of a flattened structured document.
+//
+// Nested objects are flattened to separator-joined keys, e.g. `logger/level`,
+// array elements are stored by indexed keys, e.g. `backends/0/host`.
+// Arrays of scalars are listed by the array key as a single value,
+// so they should be decoded to joined slice fields, elements are
+// available by indexed keys but not listed.
+//
+// Scalar values keep their native types, e.g. a YAML integer is assigned
+// to an int field without formatting and parsing. Strings are stored
+// as [marshaler.StringValue], so they could be interpolated and resolved.
+type KV struct {
+	values map[string]marshaler.Value
+	// leafs are sorted keys of scalar values.
+	leafs []string
+}
+
+// Parse parses the document of the format and flattens it to KV.
+//
+// Syntax errors of dotenv and properties documents are returned
+// as [*ParseError] with the line number.
+func Parse(data []byte, format Format, opts ...Option) (*KV, error) {
+	cfg := newConfig(opts)
+	if pairs, ok, err := format.decodeFlat(data); ok {
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", format, err)
+		}
+		kv := &KV{values: make(map[string]marshaler.Value, len(pairs))}
+		for _, p := range pairs {
+			key := strings.Join(p.path, cfg.sep)
+			if _, ok := kv.values[key]; !ok {
+				kv.leafs = append(kv.leafs, key)
+			}
+			kv.values[key] = marshaler.NewStringValue(p.value)
+		}
+		sort.Strings(kv.leafs)
+		return kv, nil
+	}
+	doc, err := format.decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", format, err)
+	}
+	root, ok := doc.(map[string]any)
+	if !ok && doc != nil {
+		return nil, fmt.Errorf("parse %s: document root is not an object", format)
+	}
+	kv := &KV{values: make(map[string]marshaler.Value)}
+	kv.flatten(cfg.sep, "", root)
+	sort.Strings(kv.leafs)
+	return kv, nil
+}
+
+// Load reads and parses the file, the format is detected by the file
+// extension: `.json`, `.yaml`, `.yml`, `.toml`, `.env` or `.properties`.
+func Load(path string, opts ...Option) (*KV, error) {
+	format, err := formatOf(filepath.Ext(path))
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	return Parse(data, format, opts...)
+}
+
+func (kv *KV) Get(ctx context.Context, key string) (marshaler.Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	val, ok := kv.values[key]
+	if !ok {
+		return marshaler.NullValue, nil
+	}
+	return val, nil
+}
+
+// List returns keys of scalar values which start with the prefix.
+func (kv *KV) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	for _, k := range kv.leafs {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (kv *KV) flatten(sep, key string, node any) {
+	join := func(segment string) string {
+		if key == "" {
+			return segment
+		}
+		return key + sep + segment
+	}
+	switch node := node.(type) {
+	case nil:
+		// null values are missing keys
+	case map[string]any:
+		for k, v := range node {
+			kv.flatten(sep, join(k), v)
+		}
+	case map[any]any:
+		for k, v := range node {
+			kv.flatten(sep, join(fmt.Sprint(k)), v)
+		}
+	case []any:
+		if isScalars(node) {
+			// arrays of scalars are stored in both joined
+			// and indexed layouts.
+			kv.values[key] = listValue(node)
+			kv.leafs = append(kv.leafs, key)
+			for i, v := range node {
+				if v != nil {
+					kv.flatten(sep, join(strconv.Itoa(i)), v)
+				}
+			}
+			return
+		}
+		for i, v := range node {
+			kv.flatten(sep, join(strconv.Itoa(i)), v)
+		}
+	case []map[string]any:
+		for i, v := range node {
+			kv.flatten(sep, join(strconv.Itoa(i)), v)
+		}
+	default:
+		kv.values[key] = newScalar(node)
+		kv.leafs = append(kv.leafs, key)
+	}
+}
+
+// isScalars checks if the array is not empty and has no nested
+// objects or arrays.
+func isScalars(node []any) bool {
+	for _, v := range node {
+		switch v.(type) {
+		case map[string]any, map[any]any, []any, []map[string]any:
+			return false
+		}
+	}
+	return len(node) > 0
+}
@@ -0,0 +1,230 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/g4s8/go-marshaler"
+)
+
+type testTarget struct {
+	Host   string  `kv:"host"`
+	Port   uint16  `kv:"port"`
+	Big    int64   `kv:"big"`
+	Ratio  float64 `kv:"ratio"`
+	Debug  bool    `kv:"debug"`
+	Logger struct {
+		Level   string        `kv:"level"`
+		Timeout time.Duration `kv:"timeout"`
+	} `kv:"logger"`
+	Tags      []string          `kv:"tags"`
+	Ports     []int             `kv:"ports,indexed"`
+	Weights   [2]int            `kv:"weights"`
+	Upstreams map[string]string `kv:"upstreams"`
+	Backends  []struct {
+		Host string `kv:"host"`
+	} `kv:"backends"`
+}
+
+var testDocs = map[Format]string{
+	JSON: `{
+		"host": "localhost", "port": 8080, "big": 9007199254740993, "ratio": 0.5, "debug": true,
+		"logger": {"level": "info", "timeout": "5s"},
+		"tags": ["a", "b"], "ports": [80, 443], "weights": [1, 2],
+		"upstreams": {"a": "a.local", "b": "b.local"},
+		"backends": [{"host": "b0"}, {"host": "b1"}],
+		"empty": null
+	}`,
+	YAML: `
+host: localhost
+port: 8080
+big: 9007199254740993
+ratio: 0.5
+debug: true
+logger:
+  level: info
+  timeout: 5s
+tags: [a, b]
+ports: [80, 443]
+weights: [1, 2]
+upstreams:
+  a: a.local
+  b: b.local
+backends:
+  - host: b0
+  - host: b1
+empty: ~
+`,
+	TOML: `
+host = "localhost"
+port = 8080
+big = 9007199254740993
+ratio = 0.5
+debug = true
+tags = ["a", "b"]
+ports = [80, 443]
+weights = [1, 2]
+
+[logger]
+level = "info"
+timeout = "5s"
+
+[upstreams]
+a = "a.local"
+b = "b.local"
+
+[[backends]]
+host = "b0"
+
+[[backends]]
+host = "b1"
+`,
+}
+
+func TestKV(t *testing.T) {
+	for format, doc := range testDocs {
+		t.Run(format.String(), func(t *testing.T) {
+			kv, err := Parse([]byte(doc), format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			dec, err := marshaler.NewDecoder(kv, marshaler.WithStrict())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var cfg testTarget
+			if err := dec.Decode(&cfg); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Host != "localhost" || cfg.Port != 8080 || cfg.Ratio != 0.5 || !cfg.Debug {
+				t.Fatalf("unexpected config: %+v", cfg)
+			}
+			if cfg.Big != 9007199254740993 {
+				t.Fatalf("expected %d, got %d", int64(9007199254740993), cfg.Big)
+			}
+			if cfg.Logger.Level != "info" || cfg.Logger.Timeout != 5*time.Second {
+				t.Fatalf("unexpected logger: %+v", cfg.Logger)
+			}
+			if !reflect.DeepEqual(cfg.Tags, []string{"a", "b"}) || !reflect.DeepEqual(cfg.Ports, []int{80, 443}) {
+				t.Fatalf("unexpected slices: %v, %v", cfg.Tags, cfg.Ports)
+			}
+			if cfg.Weights != [2]int{1, 2} {
+				t.Fatalf("unexpected array: %v", cfg.Weights)
+			}
+			if !reflect.DeepEqual(cfg.Upstreams, map[string]string{"a": "a.local", "b": "b.local"}) {
+				t.Fatalf("unexpected upstreams: %v", cfg.Upstreams)
+			}
+			if len(cfg.Backends) != 2 || cfg.Backends[0].Host != "b0" || cfg.Backends[1].Host != "b1" {
+				t.Fatalf("unexpected backends: %+v", cfg.Backends)
+			}
+		})
+	}
+}
+
+func TestKVValues(t *testing.T) {
+	kv, err := Parse([]byte(`{"port": 70000, "neg": -1, "name": "x", "list": [1, 2]}`), JSON,
+		WithSeparator("."))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	get := func(key string) marshaler.Value {
+		val, err := kv.Get(context.Background(), key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return val
+	}
+	t.Run("Overflow", func(t *testing.T) {
+		var port uint16
+		if err := get("port").UnmarshalTo(&port, marshaler.ValueUnmarshalOpts{}); err == nil {
+			t.Fatalf("expected overflow error")
+		}
+		var u uint
+		if err := get("neg").UnmarshalTo(&u, marshaler.ValueUnmarshalOpts{}); err == nil {
+			t.Fatalf("expected overflow error")
+		}
+	})
+	t.Run("String", func(t *testing.T) {
+		var s string
+		if err := get("port").UnmarshalTo(&s, marshaler.ValueUnmarshalOpts{}); err != nil || s != "70000" {
+			t.Fatalf("unexpected result: %q, %v", s, err)
+		}
+		if err := get("list").UnmarshalTo(&s, marshaler.ValueUnmarshalOpts{SliceSep: ";"}); err != nil || s != "1;2" {
+			t.Fatalf("unexpected result: %q, %v", s, err)
+		}
+	})
+	t.Run("List", func(t *testing.T) {
+		keys, err := kv.List(context.Background(), "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := "list, list.0, list.1, name, neg, port"
+		if actual := strings.Join(keys, ", "); actual != expected {
+			t.Fatalf("expected %q, got %q", expected, actual)
+		}
+		if get("missing") != marshaler.NullValue {
+			t.Fatalf("expected null value")
+		}
+	})
+}
+
+func TestKVReferences(t *testing.T) {
+	t.Setenv("FILE_TEST_SECRET", "secret")
+	docs := map[Format]string{
+		Dotenv: "BASE=https://example.com\nURL=${base}/api\nPASS=env://FILE_TEST_SECRET\n",
+		YAML:   "base: https://example.com\nurl: ${base}/api\npass: env://FILE_TEST_SECRET\n",
+	}
+	for format, doc := range docs {
+		t.Run(format.String(), func(t *testing.T) {
+			kv, err := Parse([]byte(doc), format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			dec, err := marshaler.NewDecoder(kv, marshaler.WithInterpolation(),
+				marshaler.WithResolver("env", marshaler.EnvResolver()))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var cfg struct {
+				URL  string `kv:"url"`
+				Pass string `kv:"pass"`
+			}
+			if err := dec.Decode(&cfg); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.URL != "https://example.com/api" || cfg.Pass != "secret" {
+				t.Fatalf("unexpected config: %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(path, []byte(testDocs[YAML]), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	kv, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var cfg testTarget
+	if err := marshaler.Unmarshal(kv, &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Host != "localhost" {
+		t.Fatalf("expected %q, got %q", "localhost", cfg.Host)
+	}
+	if _, err := Load(filepath.Join(dir, "config.ini")); err == nil {
+		t.Fatalf("expected unsupported extension error")
+	}
+	if _, err := Parse([]byte("[1, 2]"), JSON); err == nil {
+		t.Fatalf("expected root object error")
+	}
+}
@@ -0,0 +1,29 @@
+package file
+
+type config struct {
+	sep string
+}
+
+// Option configures flattening of documents.
+type Option func(*config)
+
+// WithSeparator sets the separator of flattened keys,
+// it should match the decoder key separator.
+//
+// Default is "/".
+func WithSeparator(sep string) Option {
+	return func(c *config) {
+		c.sep = sep
+	}
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{sep: "/"}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.sep == "" {
+		cfg.sep = "/"
+	}
+	return cfg
+}
@@ -0,0 +1,174 @@
+package file
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/g4s8/go-marshaler"
+)
+
+var (
+	_ marshaler.Value = scalarValue{}
+	_ marshaler.Value = listValue{}
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// scalarValue is a document scalar of native type, e.g. int64 or bool.
+//
+// It's assigned to compatible targets directly, other targets
+// are unmarshaled from the string representation like [marshaler.StringValue].
+type scalarValue struct {
+	v any
+}
+
+func (v scalarValue) UnmarshalTo(out any, opts marshaler.ValueUnmarshalOpts) error {
+	if ok, err := v.assign(out); ok {
+		return err
+	}
+	return marshaler.NewStringValue(v.String()).UnmarshalTo(out, opts)
+}
+
+// String returns the string representation of the scalar.
+func (v scalarValue) String() string {
+	switch s := v.v.(type) {
+	case string:
+		return s
+	case time.Time:
+		return s.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v.v)
+}
+
+// newScalar returns the value of a document scalar, strings
+// are string values to be interpolated and resolved by the decoder.
+func newScalar(v any) marshaler.Value {
+	if s, ok := v.(string); ok {
+		return marshaler.NewStringValue(s)
+	}
+	return scalarValue{v: v}
+}
+
+// assign assigns the native value to the out pointer if the types are
+// compatible, it returns false if the value should be converted from string.
+func (v scalarValue) assign(out any) (bool, error) {
+	if isUnmarshaler(out) {
+		return false, nil
+	}
+	ptr := reflect.ValueOf(out)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return false, nil
+	}
+	dst, src := ptr.Elem(), reflect.ValueOf(v.v)
+	if src.Type() == dst.Type() {
+		dst.Set(src)
+		return true, nil
+	}
+	if dst.Type() == durationType {
+		// numbers are not durations, e.g. `5` is not 5ns
+		return false, nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch src.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if dst.OverflowInt(src.Int()) {
+				return true, fmt.Errorf("value %v overflows %s", v.v, dst.Type())
+			}
+			dst.SetInt(src.Int())
+			return true, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if src.Uint() > 1<<63-1 || dst.OverflowInt(int64(src.Uint())) {
+				return true, fmt.Errorf("value %v overflows %s", v.v, dst.Type())
+			}
+			dst.SetInt(int64(src.Uint()))
+			return true, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch src.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if src.Int() < 0 || dst.OverflowUint(uint64(src.Int())) {
+				return true, fmt.Errorf("value %v overflows %s", v.v, dst.Type())
+			}
+			dst.SetUint(uint64(src.Int()))
+			return true, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if dst.OverflowUint(src.Uint()) {
+				return true, fmt.Errorf("value %v overflows %s", v.v, dst.Type())
+			}
+			dst.SetUint(src.Uint())
+			return true, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch src.Kind() {
+		case reflect.Float32, reflect.Float64:
+			dst.SetFloat(src.Float())
+			return true, nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			dst.SetFloat(float64(src.Int()))
+			return true, nil
+		}
+	case reflect.Bool:
+		if src.Kind() == reflect.Bool {
+			dst.SetBool(src.Bool())
+			return true, nil
+		}
+	case reflect.String:
+		if src.Kind() == reflect.String {
+			dst.SetString(src.String())
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// listValue is an array of scalars, which could be decoded
+// to a slice or array field with joined layout.
+type listValue []any
+
+func (v listValue) UnmarshalTo(out any, opts marshaler.ValueUnmarshalOpts) error {
+	ptr := reflect.ValueOf(out)
+	if isUnmarshaler(out) || ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return marshaler.NewStringValue(v.join(opts.SliceSep)).UnmarshalTo(out, opts)
+	}
+	dst := ptr.Elem()
+	switch dst.Kind() {
+	case reflect.Slice:
+		dst.Set(reflect.MakeSlice(dst.Type(), len(v), len(v)))
+	case reflect.Array:
+	default:
+		return marshaler.NewStringValue(v.join(opts.SliceSep)).UnmarshalTo(out, opts)
+	}
+	for i := 0; i < len(v) && i < dst.Len(); i++ {
+		if err := (scalarValue{v: v[i]}).UnmarshalTo(dst.Index(i).Addr().Interface(), opts); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// String returns comma-separated elements.
+func (v listValue) String() string {
+	return v.join(",")
+}
+
+func (v listValue) join(sep string) string {
+	items := make([]string, len(v))
+	for i, item := range v {
+		items[i] = scalarValue{v: item}.String()
+	}
+	return strings.Join(items, sep)
+}
+
+func isUnmarshaler(out any) bool {
+	switch out.(type) {
+	case marshaler.Scanner, encoding.TextUnmarshaler, encoding.BinaryUnmarshaler, json.Unmarshaler:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package marshaler
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	_ KV     = (*FlagKV)(nil)
+	_ Lister = (*FlagKV)(nil)
+)
+
+// flagSep is a separator of key path segments in flag names.
+const flagSep = "."
+
+// FlagKV is a key-value storage over command-line flags
+// registered by [BindFlags].
+//
+// It returns values only of flags which were set, e.g. by parsing
+// the command line, so it could be used as the top layer of [Layered]
+// without overriding other layers with zero values.
+type FlagKV struct {
+	// flags maps storage keys to flag values.
+	flags map[string]*flagValue
+}
+
+// BindFlags registers a flag in fs for each value field of the struct v,
+// e.g. `--logger.level` for the `level` field of the nested `logger` struct.
+//
+// Flag names are key paths joined by dot, default values and usage
+// text are taken from `default` and `usage` struct tags. Flags of boolean
+// fields could be set without a value, e.g. `--debug`. Values are checked
+// by parsing them to the field type when flags are set.
+//
+// The v is a struct or a pointer to a struct, it's used only to get
+// the struct type. Options are the same as for [NewDecoder], so the keys
+// of the returned KV match the keys of the decoder. Map fields, indexed
+// slices and recursive structs are skipped, since their keys are not
+// known in advance.
+func BindFlags(fs *flag.FlagSet, v any, opts ...DecoderOption) (*FlagKV, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("flags source must be a struct or a pointer to a struct")
+	}
+	cfg, err := newDecoderConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	keys := newKeyBuilder(cfg)
+	plan, err := newPlanCache(cfg, keys).get(t)
+	if err != nil {
+		return nil, err
+	}
+	kv := &FlagKV{flags: make(map[string]*flagValue)}
+	if err := kv.bind(fs, plan.fields); err != nil {
+		return nil, err
+	}
+	return kv, nil
+}
+
+func (kv *FlagKV) bind(fs *flag.FlagSet, fields []fieldPlan) error {
+	for i := range fields {
+		fp := &fields[i]
+		switch fp.kind {
+		case structFieldKind:
+			if err := kv.bind(fs, fp.fields); err != nil {
+				return err
+			}
+			continue
+		case mapField, indexedField:
+			continue
+		}
+		name := strings.Join(fp.path, flagSep)
+		if fs.Lookup(name) != nil {
+			return fmt.Errorf("flag %q of field %s is already defined", name, fp.field)
+		}
+		typ := fp.typ
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		fv := &flagValue{typ: typ, opts: fp.opts, isBool: typ.Kind() == reflect.Bool && !isValueType(typ)}
+		if fp.spec.hasDef {
+			fv.value = fp.spec.def
+		}
+		fs.Var(fv, name, fp.spec.usage)
+		if fp.secret {
+			fs.Lookup(name).DefValue = ""
+		}
+		kv.flags[fp.key] = fv
+	}
+	return nil
+}
+
+func (kv *FlagKV) Get(ctx context.Context, key string) (Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	fv, ok := kv.flags[key]
+	if !ok {
+		return NullValue, nil
+	}
+	val, ok := fv.get()
+	if !ok {
+		return NullValue, nil
+	}
+	return NewStringValue(val), nil
+}
+
+// List returns sorted keys of set flags which start with the prefix.
+func (kv *FlagKV) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	for k, fv := range kv.flags {
+		if _, ok := fv.get(); ok && strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// flagValue is a flag.Value of a struct field.
+type flagValue struct {
+	// typ is the field type, pointers are dereferenced.
+	typ    reflect.Type
+	opts   ValueUnmarshalOpts
+	isBool bool
+
+	mux   sync.RWMutex
+	value string
+	set   bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+	return f.value
+}
+
+// Set checks that the value could be parsed to the field type
+// and stores it as is.
+func (f *flagValue) Set(s string) error {
+	if err := NewStringValue(s).UnmarshalTo(reflect.New(f.typ).Interface(), f.opts); err != nil {
+		return err
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.value = s
+	f.set = true
+	return nil
+}
+
+// IsBoolFlag allows to set boolean flags without a value.
+func (f *flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// get returns the value if the flag was set.
+func (f *flagValue) get() (string, bool) {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+	return f.value, f.set
+}
@@ -0,0 +1,83 @@
+package marshaler
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBindFlags(t *testing.T) {
+	type logger struct {
+		Level string `kv:"level" default:"info" usage:"log level"`
+	}
+	type config struct {
+		Host    string            `kv:"host" usage:"server host"`
+		Port    int               `kv:"port,default=8080"`
+		Debug   *bool             `kv:"debug"`
+		Timeout time.Duration     `kv:"timeout"`
+		Token   string            `kv:"token,secret,default=changeme"`
+		Logger  logger            `kv:"logger"`
+		Labels  map[string]string `kv:"labels"`
+	}
+
+	t.Run("Layered", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags, err := BindFlags(fs, &config{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := fs.Parse([]string{"--logger.level", "debug", "--debug", "--timeout=5s"}); err != nil {
+			t.Fatalf("unexpected parse error: %v", err)
+		}
+		remote := MapKV{"host": "remote", "port": "9090", "logger/level": "warn", "labels/a": "b"}
+		var cfg config
+		if err := Unmarshal(Layered(flags, remote), &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Host != "remote" || cfg.Port != 9090 || cfg.Logger.Level != "debug" ||
+			cfg.Debug == nil || !*cfg.Debug || cfg.Timeout != 5*time.Second || cfg.Token != "changeme" {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+		if cfg.Labels["a"] != "b" {
+			t.Fatalf("unexpected labels: %v", cfg.Labels)
+		}
+	})
+	t.Run("Usage", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		if _, err := BindFlags(fs, config{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if fs.Lookup("labels") != nil {
+			t.Fatalf("unexpected flag of map field")
+		}
+		var out bytes.Buffer
+		fs.SetOutput(&out)
+		fs.PrintDefaults()
+		for _, s := range []string{"server host", "log level (default info)", "(default 8080)"} {
+			if !strings.Contains(out.String(), s) {
+				t.Fatalf("expected %q in usage:\n%s", s, out.String())
+			}
+		}
+		if strings.Contains(out.String(), "changeme") {
+			t.Fatalf("secret default in usage:\n%s", out.String())
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(&bytes.Buffer{})
+		if _, err := BindFlags(fs, &config{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := fs.Parse([]string{"--port=abc"}); err == nil {
+			t.Fatalf("expected parse error")
+		}
+		if _, err := BindFlags(fs, &config{}); err == nil || !strings.Contains(err.Error(), "already defined") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := BindFlags(fs, "config"); err == nil {
+			t.Fatalf("expected error for non-struct source")
+		}
+	})
+}
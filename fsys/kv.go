@@ -0,0 +1,174 @@
+package fsys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/g4s8/go-marshaler"
+)
+
+var (
+	_ marshaler.KV     = (*KV)(nil)
+	_ marshaler.Lister = (*KV)(nil)
+)
+
+// KV is a key-value storage over a file system tree, where each file
+// is a value and path segments are key segments, e.g. the file
+// `logger/level` is the value of `logger/level` key.
+//
+// It works with any [fs.FS], e.g. [os.DirFS] for Kubernetes ConfigMap
+// and Secret volumes, [embed.FS] for compiled-in defaults or
+// [testing/fstest.MapFS] for tests. Hidden files and directories
+// (starting with `.`) are not listed, it skips `..data` directories
+// of Kubernetes volumes. Symbolic links to files are supported,
+// but symbolic links to directories are not followed while listing.
+type KV struct {
+	fsys fs.FS
+	sep  string
+	trim bool
+}
+
+// Option configures file system KV.
+type Option func(*KV)
+
+// WithSeparator sets the key separator which is translated
+// to the path separator, it should match the decoder key separator.
+//
+// Default is "/".
+func WithSeparator(sep string) Option {
+	return func(kv *KV) {
+		kv.sep = sep
+	}
+}
+
+// WithTrimNewline enables trimming of a single trailing newline
+// of file contents, which is usually added by editors and tools.
+func WithTrimNewline() Option {
+	return func(kv *KV) {
+		kv.trim = true
+	}
+}
+
+// New creates a new KV over the file system.
+func New(fsys fs.FS, opts ...Option) *KV {
+	kv := &KV{fsys: fsys, sep: "/"}
+	for _, opt := range opts {
+		opt(kv)
+	}
+	if kv.sep == "" {
+		kv.sep = "/"
+	}
+	return kv
+}
+
+func (kv *KV) Get(ctx context.Context, key string) (marshaler.Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	name := kv.path(key)
+	if !fs.ValidPath(name) || name == "." {
+		return marshaler.NullValue, nil
+	}
+	info, err := fs.Stat(kv.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return marshaler.NullValue, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("stat %q: %w", name, err)
+	}
+	if info.IsDir() {
+		return marshaler.NullValue, nil
+	}
+	data, err := fs.ReadFile(kv.fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", name, err)
+	}
+	if kv.trim {
+		data = trimNewline(data)
+	}
+	return marshaler.NewBytesValue(data), nil
+}
+
+// List returns keys of files which start with the prefix.
+func (kv *KV) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// walk the deepest directory of the prefix
+	root := kv.path(prefix)
+	if i := strings.LastIndex(root, "/"); i > 0 {
+		root = root[:i]
+	} else {
+		root = "."
+	}
+	if !fs.ValidPath(root) {
+		return []string{}, nil
+	}
+
+	keys := make([]string, 0)
+	err := fs.WalkDir(kv.fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && name == root {
+			return fs.SkipAll
+		}
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if name != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			info, err := fs.Stat(kv.fsys, name)
+			if err != nil || info.IsDir() {
+				return nil // broken link or link to directory
+			}
+		}
+		if key := kv.key(name); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %q: %w", root, err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// path returns the file path of the key.
+func (kv *KV) path(key string) string {
+	if kv.sep == "/" {
+		return key
+	}
+	return strings.ReplaceAll(key, kv.sep, "/")
+}
+
+// key returns the key of the file path.
+func (kv *KV) key(name string) string {
+	if kv.sep == "/" {
+		return name
+	}
+	return strings.ReplaceAll(name, "/", kv.sep)
+}
+
+// trimNewline trims a single trailing `\n` or `\r\n`.
+func trimNewline(data []byte) []byte {
+	if n := len(data); n > 0 && data[n-1] == '\n' {
+		data = data[:n-1]
+		if n := len(data); n > 0 && data[n-1] == '\r' {
+			data = data[:n-1]
+		}
+	}
+	return data
+}
@@ -0,0 +1,142 @@
+package fsys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/g4s8/go-marshaler"
+)
+
+type testTarget struct {
+	Host   string `kv:"host"`
+	Port   int    `kv:"port"`
+	Logger struct {
+		Level string `kv:"level"`
+	} `kv:"logger"`
+	Upstreams map[string]string `kv:"upstreams"`
+	Backends  []struct {
+		Host string `kv:"host"`
+	} `kv:"backends"`
+}
+
+func TestKV(t *testing.T) {
+	fsys := fstest.MapFS{
+		"host":              {Data: []byte("localhost\n")},
+		"port":              {Data: []byte("8080\n")},
+		"logger/level":      {Data: []byte("info")},
+		"upstreams/a":       {Data: []byte("a.local\n")},
+		"upstreams/b":       {Data: []byte("b.local\n")},
+		"backends/0/host":   {Data: []byte("b0")},
+		"backends/1/host":   {Data: []byte("b1")},
+		".hidden/key":       {Data: []byte("hidden")},
+		"upstreams/.backup": {Data: []byte("hidden")},
+	}
+	var cfg testTarget
+	dec, err := marshaler.NewDecoder(New(fsys, WithTrimNewline()), marshaler.WithStrict())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := dec.Decode(&cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Host != "localhost" || cfg.Port != 8080 || cfg.Logger.Level != "info" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Upstreams, map[string]string{"a": "a.local", "b": "b.local"}) {
+		t.Fatalf("unexpected upstreams: %v", cfg.Upstreams)
+	}
+	if len(cfg.Backends) != 2 || cfg.Backends[0].Host != "b0" || cfg.Backends[1].Host != "b1" {
+		t.Fatalf("unexpected backends: %+v", cfg.Backends)
+	}
+
+	t.Run("NoTrim", func(t *testing.T) {
+		var cfg struct {
+			Host string `kv:"host"`
+		}
+		if err := marshaler.Unmarshal(New(fsys), &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Host != "localhost\n" {
+			t.Fatalf("expected %q, got %q", "localhost\n", cfg.Host)
+		}
+	})
+	t.Run("Get", func(t *testing.T) {
+		kv := New(fsys, WithSeparator("."))
+		for key, expected := range map[string]bool{
+			"logger.level": true,
+			"logger":       false,
+			"missing":      false,
+			"../host":      false,
+			"":             false,
+		} {
+			val, err := kv.Get(context.Background(), key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if found := val != marshaler.NullValue; found != expected {
+				t.Fatalf("expected found %v of %q, got %v", expected, key, found)
+			}
+		}
+	})
+	t.Run("List", func(t *testing.T) {
+		kv := New(fsys, WithSeparator("."))
+		for prefix, expected := range map[string]string{
+			"":           "backends.0.host, backends.1.host, host, logger.level, port, upstreams.a, upstreams.b",
+			"upstreams.": "upstreams.a, upstreams.b",
+			"back":       "backends.0.host, backends.1.host",
+			"missing.":   "",
+		} {
+			keys, err := kv.List(context.Background(), prefix)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual := strings.Join(keys, ", "); actual != expected {
+				t.Fatalf("expected %q, got %q", expected, actual)
+			}
+		}
+	})
+}
+
+func TestKVDir(t *testing.T) {
+	// Kubernetes volume layout: keys are links to files in `..data`
+	dir := t.TempDir()
+	data := filepath.Join(dir, "..2024_01_01")
+	if err := os.MkdirAll(data, 0o755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, val := range map[string]string{"host": "localhost\n", "port": "8080\n"} {
+		if err := os.WriteFile(filepath.Join(data, name), []byte(val), 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	links := map[string]string{
+		"..data": "..2024_01_01",
+		"host":   "..data/host",
+		"port":   "..data/port",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Skipf("symlinks are not supported: %v", err)
+		}
+	}
+	kv := New(os.DirFS(dir), WithTrimNewline())
+	keys, err := kv.List(context.Background(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual := strings.Join(keys, ", "); actual != "host, port" {
+		t.Fatalf("expected %q, got %q", "host, port", actual)
+	}
+	var cfg testTarget
+	if err := marshaler.Unmarshal(kv, &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Host != "localhost" || cfg.Port != 8080 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
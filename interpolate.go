@@ -0,0 +1,117 @@
+package marshaler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// envRefPrefix is a prefix of environment variable references,
+// e.g. `${env:HOME}`.
+const envRefPrefix = "env:"
+
+// interpolate expands `${...}` references of the string value
+// read by the key, see WithInterpolation.
+//
+// Values without references and values which are not strings
+// are returned as is.
+func (d *Decoder) interpolate(ctx context.Context, value Value, key string) (Value, error) {
+	if !d.config.interpolate {
+		return value, nil
+	}
+	s, ok := stringOf(value)
+	if !ok || !strings.Contains(s, "${") {
+		return value, nil
+	}
+	res, err := d.expand(ctx, s, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue(res), nil
+}
+
+// stringOf returns the string representation of the value if it's
+// a [StringValue] or implements fmt.Stringer, e.g. values of backends
+// which keep native types of scalars.
+func stringOf(value Value) (string, bool) {
+	switch v := value.(type) {
+	case StringValue:
+		return v.String(), true
+	case fmt.Stringer:
+		return v.String(), true
+	}
+	return "", false
+}
+
+// expand replaces references in s, the stack is a chain of keys
+// being expanded to detect reference cycles.
+func (d *Decoder) expand(ctx context.Context, s string, stack []string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			// `$${` is an escaped `${`
+			sb.WriteString(s[:i-1])
+			sb.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		sb.WriteString(s[:i])
+		s = s[i+2:]
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference in %q", stack[len(stack)-1])
+		}
+		val, err := d.resolveRef(ctx, s[:end], stack)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(val)
+		s = s[end+1:]
+	}
+}
+
+// resolveRef resolves a single reference, which is either
+// an environment variable or a key path relative to the prefix.
+func (d *Decoder) resolveRef(ctx context.Context, ref string, stack []string) (string, error) {
+	if name, ok := strings.CutPrefix(ref, envRefPrefix); ok {
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("resolve ${%s}: environment variable is not set", ref)
+		}
+		return val, nil
+	}
+	if ref == "" {
+		return "", fmt.Errorf("empty reference in %q", stack[len(stack)-1])
+	}
+
+	key := d.keys.key(strings.Split(ref, d.keys.sep))
+	if slices.Contains(stack, key) {
+		return "", fmt.Errorf("resolve ${%s}: reference cycle %s",
+			ref, strings.Join(append(stack, key), " -> "))
+	}
+	d.consume(key)
+	value, err := d.kv.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("resolve ${%s}: %w: get key %q: %w", ref, ErrBackend, key, err)
+	}
+	if value == NullValue {
+		return "", fmt.Errorf("resolve ${%s}: key %q is missing", ref, key)
+	}
+	var raw string
+	if sv, ok := value.(StringValue); ok {
+		raw = sv.String()
+	} else if err := value.UnmarshalTo(&raw, d.config.valueOpts(tagSpec{})); err != nil {
+		return "", fmt.Errorf("resolve ${%s}: %w", ref, err)
+	}
+	return d.expand(ctx, raw, append(slices.Clip(stack), key))
+}
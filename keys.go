@@ -0,0 +1,89 @@
+package marshaler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyFunc builds a storage key from the path of key segments,
+// e.g. ["logger", "level"] for the `level` field of the nested `logger` struct.
+//
+// The segments are field tag keys, map keys and slice indexes.
+// The decoder prefix is not a part of the path, it's prepended
+// to the result of KeyFunc.
+type KeyFunc func(path []string) string
+
+// keyBuilder builds storage keys from key paths.
+//
+// It owns the key separator, prefix joining and escaping of
+// map keys which could contain the separator.
+type keyBuilder struct {
+	sep    string
+	prefix string
+	fn     KeyFunc
+
+	escaper   *strings.Replacer
+	unescaper *strings.Replacer
+}
+
+func newKeyBuilder(cfg decoderConfig) keyBuilder {
+	b := keyBuilder{sep: cfg.separator, prefix: cfg.prefix, fn: cfg.keyFunc}
+	if b.prefix != "" && !strings.HasSuffix(b.prefix, b.sep) {
+		b.prefix += b.sep
+	}
+	if b.fn == nil {
+		b.fn = func(path []string) string {
+			return strings.Join(path, b.sep)
+		}
+	}
+	escSep := percentEncode(b.sep)
+	b.escaper = strings.NewReplacer("%", "%25", b.sep, escSep)
+	b.unescaper = strings.NewReplacer("%25", "%", escSep, b.sep)
+	return b
+}
+
+// key returns the storage key of the path.
+func (b keyBuilder) key(path []string) string {
+	return b.prefix + b.fn(path)
+}
+
+// listPrefix returns the prefix of all nested keys of the path.
+func (b keyBuilder) listPrefix(path []string) string {
+	return b.key(path) + b.sep
+}
+
+// children returns direct children segments of the prefix,
+// if leafs is true, only children without nested keys are returned.
+func (b keyBuilder) children(keys []string, prefix string, leafs bool) []string {
+	if leafs {
+		keys = leafKeys(keys, prefix, b.sep)
+	}
+	return childKeys(keys, prefix, b.sep)
+}
+
+// escape escapes the separator in a segment, which is not a part
+// of struct definition, e.g. a map key.
+func (b keyBuilder) escape(segment string) string {
+	return b.escaper.Replace(segment)
+}
+
+// unescape is the inverse of escape.
+func (b keyBuilder) unescape(segment string) string {
+	return b.unescaper.Replace(segment)
+}
+
+// appendPath returns a new path with the segment appended,
+// it never modifies the original path.
+func appendPath(path []string, segment string) []string {
+	res := make([]string, len(path), len(path)+1)
+	copy(res, path)
+	return append(res, segment)
+}
+
+func percentEncode(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		fmt.Fprintf(&sb, "%%%02X", s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,166 @@
+package marshaler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	_ KV      = (*LayeredKV)(nil)
+	_ Lister  = (*LayeredKV)(nil)
+	_ Watcher = (*LayeredKV)(nil)
+)
+
+// LayeredKV composes multiple key-value storages with precedence,
+// e.g. environment variables over Consul over embedded defaults.
+//
+// Layers are queried in order and the first value which is not
+// [NullValue] is returned. By default, an error of any layer fails
+// the lookup, if SkipErrors is true failing layers are skipped.
+type LayeredKV struct {
+	// SkipErrors makes the lookup skip failing layers instead of
+	// returning the error. Context errors are always returned.
+	SkipErrors bool
+	// TrackSources enables recording of the layer which supplied
+	// each key, see [LayeredKV.Source].
+	TrackSources bool
+
+	layers []KV
+
+	mux     sync.Mutex
+	sources map[string]int
+}
+
+// Layered returns a KV which reads keys from layers in order,
+// the first layer has the highest precedence.
+func Layered(kvs ...KV) *LayeredKV {
+	return &LayeredKV{layers: kvs}
+}
+
+// Get returns the value of the first layer which has the key.
+func (l *LayeredKV) Get(ctx context.Context, key string) (Value, error) {
+	for i, kv := range l.layers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		val, err := kv.Get(ctx, key)
+		if err != nil {
+			if l.SkipErrors && ctx.Err() == nil {
+				continue
+			}
+			return nil, fmt.Errorf("layer %d: %w", i, err)
+		}
+		if val == NullValue {
+			continue
+		}
+		l.track(key, i)
+		return val, nil
+	}
+	return NullValue, nil
+}
+
+// List returns sorted unique keys of all layers which implement [Lister].
+//
+// It returns [ErrNotSupported] if none of the layers supports listing.
+func (l *LayeredKV) List(ctx context.Context, prefix string) ([]string, error) {
+	seen := make(map[string]struct{})
+	var hasLister bool
+	for i, kv := range l.layers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		lister, ok := kv.(Lister)
+		if !ok {
+			continue
+		}
+		hasLister = true
+		keys, err := lister.List(ctx, prefix)
+		if err != nil {
+			if l.SkipErrors && ctx.Err() == nil {
+				continue
+			}
+			return nil, fmt.Errorf("layer %d: %w", i, err)
+		}
+		for _, k := range keys {
+			seen[k] = struct{}{}
+		}
+	}
+	if !hasLister {
+		return nil, fmt.Errorf("%w: none of the layers supports listing keys", ErrNotSupported)
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Watch merges change notifications of all layers which implement [Watcher].
+//
+// It returns [ErrNotSupported] if none of the layers supports watching, the
+// channel is closed when watching of any layer stops.
+func (l *LayeredKV) Watch(ctx context.Context, prefix string) (<-chan struct{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	var chans []<-chan struct{}
+	for i, kv := range l.layers {
+		watcher, ok := kv.(Watcher)
+		if !ok {
+			continue
+		}
+		ch, err := watcher.Watch(ctx, prefix)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("layer %d: %w", i, err)
+		}
+		chans = append(chans, ch)
+	}
+	if len(chans) == 0 {
+		cancel()
+		return nil, fmt.Errorf("%w: none of the layers supports watching", ErrNotSupported)
+	}
+	out := make(chan struct{}, 1)
+	var wg sync.WaitGroup
+	for _, ch := range chans {
+		wg.Add(1)
+		go func(ch <-chan struct{}) {
+			defer wg.Done()
+			defer cancel() // stop other layers
+			for range ch {
+				select {
+				case out <- struct{}{}:
+				default:
+				}
+			}
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(out)
+	}()
+	return out, nil
+}
+
+// Source returns the index of the layer which supplied the key,
+// or false if the key was not found or TrackSources is not enabled.
+func (l *LayeredKV) Source(key string) (int, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	i, ok := l.sources[key]
+	return i, ok
+}
+
+func (l *LayeredKV) track(key string, layer int) {
+	if !l.TrackSources {
+		return
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.sources == nil {
+		l.sources = make(map[string]int)
+	}
+	l.sources[key] = layer
+}
@@ -0,0 +1,99 @@
+package marshaler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLayered(t *testing.T) {
+	env := MapKV{"logger/level": "debug"}
+	remote := MapKV{"logger/level": "info", "port": "8080", "upstreams/b": "b.remote"}
+	defaults := newKVStub().With("port", "80").With("host", "localhost")
+
+	t.Run("Get", func(t *testing.T) {
+		kv := Layered(env, remote, defaults)
+		kv.TrackSources = true
+		var cfg struct {
+			Level     string            `kv:"logger/level"`
+			Port      int               `kv:"port"`
+			Host      string            `kv:"host"`
+			Missing   string            `kv:"missing"`
+			Upstreams map[string]string `kv:"upstreams"`
+		}
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Level != "debug" || cfg.Port != 8080 || cfg.Host != "localhost" || cfg.Missing != "" {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+		if cfg.Upstreams["b"] != "b.remote" {
+			t.Fatalf("unexpected upstreams: %v", cfg.Upstreams)
+		}
+		for key, expected := range map[string]int{"logger/level": 0, "port": 1, "host": 2} {
+			if layer, ok := kv.Source(key); !ok || layer != expected {
+				t.Fatalf("expected layer %d of %q, got %d", expected, key, layer)
+			}
+		}
+		if _, ok := kv.Source("missing"); ok {
+			t.Fatalf("unexpected source of missing key")
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		kv := Layered(errKV{}, remote)
+		if _, err := kv.Get(context.Background(), "port"); err == nil ||
+			!strings.Contains(err.Error(), "layer 0: connection refused") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		kv.SkipErrors = true
+		val, err := kv.Get(context.Background(), "port")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if s, ok := val.(StringValue); !ok || s.String() != "8080" {
+			t.Fatalf("expected %q, got %v", "8080", val)
+		}
+		if _, ok := kv.Source("port"); ok {
+			t.Fatalf("unexpected source without tracking")
+		}
+	})
+	t.Run("List", func(t *testing.T) {
+		keys, err := Layered(env, defaults, remote).List(context.Background(), "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := "logger/level, port, upstreams/b"
+		if actual := strings.Join(keys, ", "); actual != expected {
+			t.Fatalf("expected %q, got %q", expected, actual)
+		}
+		if _, err := Layered(defaults).List(context.Background(), ""); !errors.Is(err, ErrNotSupported) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Probe", func(t *testing.T) {
+		// indexes are probed if none of the layers supports listing.
+		var cfg struct {
+			Ports []int `kv:"ports,indexed"`
+		}
+		kv := Layered(newKVStub().With("ports/0", "1").With("ports/1", "2"))
+		if err := Unmarshal(kv, &cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cfg.Ports) != 2 || cfg.Ports[0] != 1 || cfg.Ports[1] != 2 {
+			t.Fatalf("unexpected ports: %v", cfg.Ports)
+		}
+	})
+	t.Run("Context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		kv := Layered(env, remote)
+		kv.SkipErrors = true
+		if _, err := kv.Get(ctx, "port"); !errors.Is(err, context.Canceled) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := kv.List(ctx, ""); !errors.Is(err, context.Canceled) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
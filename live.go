@@ -0,0 +1,249 @@
+package marshaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// Errors for live options validation.
+	ErrPollInterval  = fmt.Errorf("poll interval must be positive")
+	ErrNilErrHandler = fmt.Errorf("nil error handler")
+)
+
+// ErrNoRefresh is returned by [Live.Run] if the value could not be refreshed.
+var ErrNoRefresh = fmt.Errorf("kv doesn't support watching and poll interval is not set")
+
+// Validator is an optional interface of config types to check
+// decoded values, see [Live].
+type Validator interface {
+	// Validate returns an error if the value is invalid.
+	Validate() error
+}
+
+type liveConfig struct {
+	interval time.Duration
+	errFn    func(error)
+}
+
+// LiveOption is an option for [Live] configuration.
+//
+// It returns an error if the option parameter is invalid.
+type LiveOption func(*liveConfig) error
+
+// WithPollInterval makes [Live] decode the value periodically,
+// in addition to watching if the KV implements [Watcher].
+func WithPollInterval(d time.Duration) LiveOption {
+	return func(c *liveConfig) error {
+		if d <= 0 {
+			return ErrPollInterval
+		}
+		c.interval = d
+		return nil
+	}
+}
+
+// WithErrorHandler sets the handler of refresh errors, e.g. to log them.
+// The last good value is kept on errors.
+func WithErrorHandler(fn func(error)) LiveOption {
+	return func(c *liveConfig) error {
+		if fn == nil {
+			return ErrNilErrHandler
+		}
+		c.errFn = fn
+		return nil
+	}
+}
+
+// Live holds a decoded value of T which is refreshed on changes
+// of the key-value storage.
+//
+// The value is replaced atomically, only if the new value is decoded
+// and validated successfully and it differs from the current one,
+// so [Live.Load] always returns the last good value. The value is
+// validated if *T implements [Validator].
+//
+// Values returned by [Live.Load] are shared and must not be modified.
+type Live[T any] struct {
+	dec    *Decoder
+	config liveConfig
+	value  atomic.Pointer[T]
+
+	// mux serializes updates and subscribers calls.
+	mux sync.Mutex
+
+	subsMux sync.Mutex
+	subs    []subscriber[T]
+	nextID  int
+}
+
+type subscriber[T any] struct {
+	id int
+	fn func(old, new *T)
+}
+
+// NewLive decodes the initial value of T using the decoder,
+// it returns an error if the value could not be decoded or is invalid.
+//
+// The value is not refreshed until [Live.Run] is called.
+func NewLive[T any](ctx context.Context, dec *Decoder, opts ...LiveOption) (*Live[T], error) {
+	var cfg liveConfig
+	var errs []error
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	l := &Live[T]{dec: dec, config: cfg}
+	v, err := l.decode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l.value.Store(v)
+	return l, nil
+}
+
+// Load returns the current value, it never blocks.
+func (l *Live[T]) Load() *T {
+	return l.value.Load()
+}
+
+// Subscribe registers fn to be called after the value is replaced,
+// e.g. to rebuild state which depends on the config. Subscribers
+// are called sequentially in the refreshing goroutine, in order
+// of subscription.
+//
+// It returns a function to unsubscribe.
+func (l *Live[T]) Subscribe(fn func(old, new *T)) (unsubscribe func()) {
+	l.subsMux.Lock()
+	defer l.subsMux.Unlock()
+	id := l.nextID
+	l.nextID++
+	l.subs = append(l.subs, subscriber[T]{id: id, fn: fn})
+	return func() {
+		l.subsMux.Lock()
+		defer l.subsMux.Unlock()
+		for i, sub := range l.subs {
+			if sub.id == id {
+				l.subs = append(l.subs[:i:i], l.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Run refreshes the value on changes until ctx is done, it returns nil then.
+//
+// The value is refreshed on watch events if the KV implements [Watcher]
+// and periodically if the poll interval is set by [WithPollInterval].
+// It returns [ErrNoRefresh] if none of them is available, e.g. if
+// none of the layers of [LayeredKV] implements [Watcher],
+// or the error of [Decoder.Watch] if watching fails.
+func (l *Live[T]) Run(ctx context.Context) error {
+	_, watch := l.dec.kv.(Watcher)
+	if !watch && l.config.interval == 0 {
+		return ErrNoRefresh
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	if l.config.interval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.poll(ctx)
+		}()
+	}
+	var err error
+	if watch {
+		err = l.dec.Watch(ctx, func() any { return new(T) }, func(v any, err error) {
+			if err == nil {
+				err = l.validate(v.(*T))
+			}
+			l.update(v.(*T), err)
+		})
+		if errors.Is(err, ErrNotSupported) {
+			// none of the wrapped storages supports watching.
+			if l.config.interval == 0 {
+				err = ErrNoRefresh
+			} else {
+				err = nil
+				<-ctx.Done()
+			}
+		}
+		cancel()
+	}
+	wg.Wait()
+	return err
+}
+
+func (l *Live[T]) poll(ctx context.Context) {
+	ticker := time.NewTicker(l.config.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		v, err := l.decode(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		l.update(v, err)
+	}
+}
+
+// decode decodes and validates a new value.
+func (l *Live[T]) decode(ctx context.Context) (*T, error) {
+	v := new(T)
+	if err := l.dec.DecodeContext(ctx, v); err != nil {
+		return nil, err
+	}
+	if err := l.validate(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (l *Live[T]) validate(v *T) error {
+	if val, ok := any(v).(Validator); ok {
+		if err := val.Validate(); err != nil {
+			return fmt.Errorf("validate: %w", err)
+		}
+	}
+	return nil
+}
+
+// update replaces the value and notifies subscribers if the value
+// is changed, or passes the error to the error handler.
+func (l *Live[T]) update(v *T, err error) {
+	if err != nil {
+		if l.config.errFn != nil {
+			l.config.errFn(err)
+		}
+		return
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	old := l.value.Load()
+	if reflect.DeepEqual(old, v) {
+		return
+	}
+	l.value.Store(v)
+	l.subsMux.Lock()
+	subs := l.subs
+	l.subsMux.Unlock()
+	for _, sub := range subs {
+		sub.fn(old, v)
+	}
+}
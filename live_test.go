@@ -0,0 +1,184 @@
+package marshaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type liveTarget struct {
+	Port int `kv:"port"`
+}
+
+func (c *liveTarget) Validate() error {
+	if c.Port <= 0 {
+		return fmt.Errorf("invalid port %d", c.Port)
+	}
+	return nil
+}
+
+// pollKV is a watchKV which doesn't support watching.
+type pollKV struct {
+	w *watchKV
+}
+
+func (p pollKV) Get(ctx context.Context, key string) (Value, error) {
+	return p.w.Get(ctx, key)
+}
+
+func (p pollKV) set(key, value string) {
+	p.w.mux.Lock()
+	defer p.w.mux.Unlock()
+	p.w.data[key] = value
+}
+
+func TestLive(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Watch", func(t *testing.T) {
+		kv := newWatchKV(MapKV{"port": "80"})
+		dec, err := NewDecoder(kv, WithDebounce(0))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		errs := make(chan error, 1)
+		live, err := NewLive[liveTarget](ctx, dec, WithErrorHandler(func(err error) { errs <- err }))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if port := live.Load().Port; port != 80 {
+			t.Fatalf("expected port 80, got %d", port)
+		}
+		type change struct{ old, new int }
+		changes := make(chan change, 1)
+		unsubscribe := live.Subscribe(func(old, new *liveTarget) {
+			changes <- change{old.Port, new.Port}
+		})
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() { done <- live.Run(runCtx) }()
+
+		kv.Put("port", "81")
+		if c := <-changes; c.old != 80 || c.new != 81 {
+			t.Fatalf("unexpected change: %+v", c)
+		}
+		kv.Put("port", "-1")
+		if err := <-errs; err == nil || !strings.Contains(err.Error(), "invalid port -1") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if port := live.Load().Port; port != 81 {
+			t.Fatalf("expected last good port 81, got %d", port)
+		}
+		unsubscribe()
+		kv.Put("port", "82")
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		select {
+		case c := <-changes:
+			t.Fatalf("unexpected change after unsubscribe: %+v", c)
+		default:
+		}
+	})
+	t.Run("Poll", func(t *testing.T) {
+		kv := pollKV{newWatchKV(MapKV{"port": "80"})}
+		dec, err := NewDecoder(kv)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		live, err := NewLive[liveTarget](ctx, dec, WithPollInterval(10*time.Millisecond))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		changes := make(chan int, 1)
+		live.Subscribe(func(_, new *liveTarget) { changes <- new.Port })
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go live.Run(runCtx)
+
+		kv.set("port", "81")
+		select {
+		case port := <-changes:
+			if port != 81 {
+				t.Fatalf("expected port 81, got %d", port)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("change was not delivered")
+		}
+		// unchanged values are not delivered.
+		select {
+		case port := <-changes:
+			t.Fatalf("unexpected change: %d", port)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+	t.Run("LayeredPoll", func(t *testing.T) {
+		// none of the layers supports watching.
+		kv := pollKV{newWatchKV(MapKV{"port": "80"})}
+		dec, err := NewDecoder(Layered(kv))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		live, err := NewLive[liveTarget](ctx, dec, WithPollInterval(10*time.Millisecond))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		changes := make(chan int, 1)
+		live.Subscribe(func(_, new *liveTarget) { changes <- new.Port })
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() { done <- live.Run(runCtx) }()
+
+		kv.set("port", "81")
+		select {
+		case port := <-changes:
+			if port != 81 {
+				t.Fatalf("expected port 81, got %d", port)
+			}
+		case err := <-done:
+			t.Fatalf("unexpected stop: %v", err)
+		case <-time.After(time.Second):
+			t.Fatalf("change was not delivered")
+		}
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		live, err = NewLive[liveTarget](ctx, dec)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := live.Run(ctx); !errors.Is(err, ErrNoRefresh) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		dec, err := NewDecoder(MapKV{"port": "0"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := NewLive[liveTarget](ctx, dec); err == nil || !strings.Contains(err.Error(), "validate") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := NewLive[liveTarget](ctx, dec, WithPollInterval(0)); !errors.Is(err, ErrPollInterval) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		dec, err = NewDecoder(MapKV{"port": "80"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		live, err := NewLive[liveTarget](ctx, dec)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := live.Run(ctx); !errors.Is(err, ErrNoRefresh) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
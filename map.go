@@ -1,6 +1,16 @@
 package marshaler
 
-import "context"
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+var (
+	_ KV       = MapKV(nil)
+	_ Lister   = MapKV(nil)
+	_ KVWriter = MapKV(nil)
+)
 
 // MapKV is a simple key-value storage implementation based on a map of strings.
 type MapKV map[string]string
@@ -12,3 +22,24 @@ func (m MapKV) Get(ctx context.Context, key string) (Value, error) {
 	}
 	return NewStringValue(val), nil
 }
+
+func (m MapKV) List(ctx context.Context, prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m MapKV) Put(ctx context.Context, key string, value string) error {
+	m[key] = value
+	return nil
+}
+
+func (m MapKV) Delete(ctx context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
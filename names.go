@@ -0,0 +1,80 @@
+package marshaler
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NameStrategy derives a key from Go field name for fields without tags.
+//
+// See [WithNameStrategy].
+type NameStrategy func(field string) string
+
+var (
+	_ NameStrategy = ExactName
+	_ NameStrategy = SnakeCase
+	_ NameStrategy = KebabCase
+	_ NameStrategy = LowerCamelCase
+)
+
+// ExactName uses the field name as is: `HTTPServer` -> `HTTPServer`.
+func ExactName(field string) string {
+	return field
+}
+
+// SnakeCase converts the field name to snake_case: `HTTPServer` -> `http_server`.
+func SnakeCase(field string) string {
+	return strings.ToLower(strings.Join(splitWords(field), "_"))
+}
+
+// KebabCase converts the field name to kebab-case: `HTTPServer` -> `http-server`.
+func KebabCase(field string) string {
+	return strings.ToLower(strings.Join(splitWords(field), "-"))
+}
+
+// LowerCamelCase converts the field name to lowerCamelCase: `HTTPServer` -> `httpServer`.
+func LowerCamelCase(field string) string {
+	words := splitWords(field)
+	if len(words) == 0 {
+		return field
+	}
+	words[0] = strings.ToLower(words[0])
+	return strings.Join(words, "")
+}
+
+// splitWords splits camel case name to words, keeping acronyms
+// together: `HTTPServerID2` -> [`HTTP`, `Server`, `ID2`].
+func splitWords(name string) []string {
+	runes := []rune(name)
+	words := make([]string, 0, 4)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		var next rune
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case cur == '_':
+			words = appendWord(words, runes[start:i])
+			start = i + 1
+		case unicode.IsLower(prev) && unicode.IsUpper(cur),
+			unicode.IsDigit(prev) && unicode.IsUpper(cur):
+			// word boundary: `userID` or `v2Server`
+			words = appendWord(words, runes[start:i])
+			start = i
+		case unicode.IsUpper(prev) && unicode.IsUpper(cur) && unicode.IsLower(next):
+			// end of acronym: `HTTPServer`
+			words = appendWord(words, runes[start:i])
+			start = i
+		}
+	}
+	return appendWord(words, runes[start:])
+}
+
+func appendWord(words []string, word []rune) []string {
+	if len(word) == 0 {
+		return words
+	}
+	return append(words, string(word))
+}
@@ -0,0 +1,35 @@
+package marshaler
+
+import "testing"
+
+func TestNameStrategy(t *testing.T) {
+	cases := []struct {
+		name                     string
+		snake, kebab, lowerCamel string
+	}{
+		{"Host", "host", "host", "host"},
+		{"MaxConns", "max_conns", "max-conns", "maxConns"},
+		{"HTTPServer", "http_server", "http-server", "httpServer"},
+		{"UserID", "user_id", "user-id", "userID"},
+		{"TLS", "tls", "tls", "tls"},
+		{"Port2", "port2", "port2", "port2"},
+		{"V2Server", "v2_server", "v2-server", "v2Server"},
+		{"Read_Timeout", "read_timeout", "read-timeout", "readTimeout"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if res := SnakeCase(c.name); res != c.snake {
+				t.Fatalf("expected %q, got %q", c.snake, res)
+			}
+			if res := KebabCase(c.name); res != c.kebab {
+				t.Fatalf("expected %q, got %q", c.kebab, res)
+			}
+			if res := LowerCamelCase(c.name); res != c.lowerCamel {
+				t.Fatalf("expected %q, got %q", c.lowerCamel, res)
+			}
+			if res := ExactName(c.name); res != c.name {
+				t.Fatalf("expected %q, got %q", c.name, res)
+			}
+		})
+	}
+}
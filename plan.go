@@ -0,0 +1,187 @@
+package marshaler
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// fieldKind defines how the field is decoded.
+type fieldKind int
+
+const (
+	// valueField is decoded from a single value.
+	valueField fieldKind = iota
+	// structFieldKind is a nested struct or a pointer to struct,
+	// inline structs are nested structs with the key path of the parent.
+	structFieldKind
+	// mapField is decoded from listed child keys.
+	mapField
+	// indexedField is a slice or array decoded from indexed keys.
+	indexedField
+)
+
+// fieldPlan is a compiled decoding plan of a struct field.
+//
+// Key and field paths are relative to the root of the plan, so the
+// plan of the decode target has ready to use keys, while plans of map
+// and slice elements are joined with element location at runtime.
+type fieldPlan struct {
+	// index is the field index in the parent struct.
+	index int
+	typ   reflect.Type
+	kind  fieldKind
+	spec  tagSpec
+
+	// path is a key path, key is the full key of the path.
+	path []string
+	key  string
+	// field is a Go field path.
+	field  string
+	secret bool
+
+	opts     ValueUnmarshalOpts
+	required bool
+
+	// fields are nested fields of struct field, if recursive is true
+	// the fields are not compiled to avoid infinite recursion, and
+	// the plan of the struct type is used instead.
+	fields    []fieldPlan
+	recursive bool
+}
+
+// structPlan is a compiled decoding plan of a struct type.
+type structPlan struct {
+	fields []fieldPlan
+	// err is an error of the struct definition, e.g. colliding keys.
+	err error
+}
+
+// planCache is a concurrency-safe cache of struct plans,
+// it's bound to decoder configuration.
+type planCache struct {
+	config decoderConfig
+	keys   keyBuilder
+	plans  sync.Map // reflect.Type -> *structPlan
+}
+
+func newPlanCache(cfg decoderConfig, keys keyBuilder) *planCache {
+	return &planCache{config: cfg, keys: keys}
+}
+
+// get returns a plan of the struct type, compiling it if needed.
+//
+// Invalid struct definitions are cached as well, so the error
+// is returned on each call.
+func (c *planCache) get(t reflect.Type) (*structPlan, error) {
+	if p, ok := c.plans.Load(t); ok {
+		p := p.(*structPlan)
+		return p, p.err
+	}
+	p := new(structPlan)
+	p.fields, p.err = c.compile(t, nil, "", false, map[reflect.Type]bool{t: true})
+	if p.err != nil {
+		p.err = fmt.Errorf("invalid struct %s: %w", t, p.err)
+	}
+	actual, _ := c.plans.LoadOrStore(t, p)
+	p = actual.(*structPlan)
+	return p, p.err
+}
+
+func (c *planCache) compile(t reflect.Type, path []string, field string,
+	secret bool, visiting map[reflect.Type]bool,
+) ([]fieldPlan, error) {
+	fields := make([]fieldPlan, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() && !isEmbeddedStruct(sf) {
+			continue // Skip unexported fields
+		}
+		spec, ok := fieldTagSpec(sf, c.config.tag, c.config.nameStrategy)
+		if !ok {
+			continue // Skip fields without tag
+		}
+
+		fp := fieldPlan{
+			index:    i,
+			typ:      sf.Type,
+			spec:     spec,
+			path:     path,
+			field:    sf.Name,
+			secret:   secret || spec.secret,
+			opts:     c.config.valueOpts(spec),
+			required: c.config.isRequired(spec),
+		}
+		if field != "" {
+			fp.field = field + "." + sf.Name
+		}
+		if !spec.inline {
+			fp.path = appendPath(path, spec.key)
+		} else if !isStructType(sf.Type) {
+			return nil, fmt.Errorf("inline field %s is not a struct", fp.field)
+		}
+		fp.key = c.keys.key(fp.path)
+
+		switch {
+		case isStructType(sf.Type):
+			fp.kind = structFieldKind
+			// nested fields are required by WithRequireAll,
+			// the struct itself only by the tag.
+			fp.required = spec.required
+			st := sf.Type
+			if st.Kind() == reflect.Ptr {
+				st = st.Elem()
+			}
+			if visiting[st] {
+				if spec.inline {
+					return nil, fmt.Errorf("inline field %s is recursive", fp.field)
+				}
+				fp.recursive = true
+				break
+			}
+			visiting[st] = true
+			nested, err := c.compile(st, fp.path, fp.field, fp.secret, visiting)
+			delete(visiting, st)
+			if err != nil {
+				return nil, err
+			}
+			fp.fields = nested
+		case sf.Type.Kind() == reflect.Map:
+			if elem := sf.Type.Elem(); elem.Kind() == reflect.Map ||
+				(!isValueType(elem) && isIndexedLayout(elem, spec)) {
+				// nested keys are not listed as map elements.
+				return nil, fmt.Errorf("%w: map field %s of %s", ErrUnsupportedType, fp.field, sf.Type)
+			}
+			fp.kind = mapField
+		case isIndexedLayout(sf.Type, spec):
+			fp.kind = indexedField
+		default:
+			fp.kind = valueField
+		}
+		fields = append(fields, fp)
+	}
+	if err := checkKeys(fields, make(map[string]string, len(fields))); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// checkKeys checks that fields don't share the same key segment,
+// fields of inline structs are checked in the key space of the parent.
+// The seen maps key segments to Go field paths.
+func checkKeys(fields []fieldPlan, seen map[string]string) error {
+	for i := range fields {
+		fp := &fields[i]
+		if fp.spec.inline {
+			if err := checkKeys(fp.fields, seen); err != nil {
+				return err
+			}
+			continue
+		}
+		if other, ok := seen[fp.spec.key]; ok {
+			return fmt.Errorf("key %q of field %s collides with field %s", fp.key, fp.field, other)
+		}
+		seen[fp.spec.key] = fp.field
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package marshaler
+
+import (
+	"context"
+	"sync"
+)
+
+// fetchedValue is a result of concurrent value fetching.
+type fetchedValue struct {
+	value Value
+	err   error
+}
+
+// fetchedKV serves values fetched in advance and falls back to
+// the underlying KV for other keys, e.g. map and slice elements.
+type fetchedKV struct {
+	kv     KV
+	values map[string]fetchedValue
+}
+
+func (f *fetchedKV) Get(ctx context.Context, key string) (Value, error) {
+	if v, ok := f.values[key]; ok {
+		return v.value, v.err
+	}
+	return f.kv.Get(ctx, key)
+}
+
+// fetchedListerKV is a fetchedKV for the KV which supports listing.
+type fetchedListerKV struct {
+	*fetchedKV
+	lister Lister
+}
+
+func (f fetchedListerKV) List(ctx context.Context, prefix string) ([]string, error) {
+	return f.lister.List(ctx, prefix)
+}
+
+// fetchConcurrently fetches values of all plan keys known in advance
+// using at most n parallel requests, and returns KV which serves them.
+//
+// Fetch errors are not returned but served by the KV, so they are
+// handled by the decoder the same way as errors of sequential fetching.
+func fetchConcurrently(ctx context.Context, kv KV, kb keyBuilder, fields []fieldPlan, n int) KV {
+	keys := planKeys(kb, location{}, fields, nil)
+	values := make(map[string]fetchedValue, len(keys))
+	var (
+		mux sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, n)
+	)
+loop:
+	for _, key := range keys {
+		select {
+		case <-ctx.Done():
+			// not fetched keys are requested by the decoder
+			// and fail with context error.
+			break loop
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(key string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			val, err := kv.Get(ctx, key)
+			mux.Lock()
+			values[key] = fetchedValue{value: val, err: err}
+			mux.Unlock()
+		}(key)
+	}
+	wg.Wait()
+
+	fkv := &fetchedKV{kv: kv, values: values}
+	if lister, ok := kv.(Lister); ok {
+		return fetchedListerKV{fetchedKV: fkv, lister: lister}
+	}
+	return fkv
+}
+
+// planKeys returns keys of value fields of the plan relative to
+// the base location, which are known before decoding.
+func planKeys(kb keyBuilder, base location, fields []fieldPlan, keys []string) []string {
+	for i := range fields {
+		fp := &fields[i]
+		switch fp.kind {
+		case valueField:
+			keys = append(keys, base.at(kb, fp).key)
+		case structFieldKind:
+			keys = planKeys(kb, base, fp.fields, keys)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,104 @@
+package marshaler
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Resolver resolves references stored in place of values,
+// e.g. `file:///run/secrets/db_pass` or `env://DB_PASS`.
+//
+// Resolvers are registered by URI scheme with [WithResolver].
+type Resolver interface {
+	// Resolve returns the value of the reference. The ref is the
+	// stored value without the `scheme:` prefix and the `//` after it,
+	// e.g. `/run/secrets/db_pass` for `file:///run/secrets/db_pass`.
+	Resolve(ctx context.Context, ref string) (Value, error)
+}
+
+// ResolverFunc is an adapter to use a function as [Resolver].
+type ResolverFunc func(ctx context.Context, ref string) (Value, error)
+
+// Resolve calls f(ctx, ref).
+func (f ResolverFunc) Resolve(ctx context.Context, ref string) (Value, error) {
+	return f(ctx, ref)
+}
+
+// FileResolver returns a resolver which reads the value from the file,
+// e.g. `file:///run/secrets/db_pass`. The trailing newline of the file
+// is trimmed.
+func FileResolver() Resolver {
+	return ResolverFunc(func(ctx context.Context, ref string) (Value, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+		return NewStringValue(trimNewline(string(data))), nil
+	})
+}
+
+// EnvResolver returns a resolver which reads the value from
+// the environment variable, e.g. `env://DB_PASS`.
+func EnvResolver() Resolver {
+	return ResolverFunc(func(_ context.Context, ref string) (Value, error) {
+		val, ok := os.LookupEnv(ref)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %q is not set", ref)
+		}
+		return NewStringValue(val), nil
+	})
+}
+
+// Base64Resolver returns a resolver which decodes standard base64
+// encoded value, e.g. `base64:c2VjcmV0`.
+func Base64Resolver() Resolver {
+	return ResolverFunc(func(_ context.Context, ref string) (Value, error) {
+		data, err := base64.StdEncoding.DecodeString(ref)
+		if err != nil {
+			return nil, fmt.Errorf("decode base64: %w", err)
+		}
+		return NewBytesValue(data), nil
+	})
+}
+
+// resolve resolves the string value by the resolver of its scheme,
+// it returns false if the value is not a reference.
+func (d *Decoder) resolve(ctx context.Context, value Value) (Value, bool, error) {
+	if len(d.config.resolvers) == 0 {
+		return value, false, nil
+	}
+	s, ok := stringOf(value)
+	if !ok {
+		return value, false, nil
+	}
+	scheme, ref, ok := strings.Cut(s, ":")
+	if !ok {
+		return value, false, nil
+	}
+	r, ok := d.config.resolvers[scheme]
+	if !ok {
+		return value, false, nil
+	}
+	res, err := r.Resolve(ctx, strings.TrimPrefix(ref, "//"))
+	if err != nil {
+		return nil, true, fmt.Errorf("resolve %s reference: %w", scheme, err)
+	}
+	if res == nil {
+		res = NullValue
+	}
+	return res, true, nil
+}
+
+// trimNewline trims a single trailing `\n` or `\r\n`.
+func trimNewline(s string) string {
+	if s, ok := strings.CutSuffix(s, "\n"); ok {
+		return strings.TrimSuffix(s, "\r")
+	}
+	return s
+}
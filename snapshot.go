@@ -0,0 +1,40 @@
+package marshaler
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+var (
+	_ KV     = snapshotKV(nil)
+	_ Lister = snapshotKV(nil)
+)
+
+// snapshotKV is an in-memory snapshot of fetched values.
+type snapshotKV map[string]Value
+
+func (s snapshotKV) Get(ctx context.Context, key string) (Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	val, ok := s[key]
+	if !ok {
+		return NullValue, nil
+	}
+	return val, nil
+}
+
+func (s snapshotKV) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	for k := range s {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
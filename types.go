@@ -2,8 +2,14 @@ package marshaler
 
 import (
 	"context"
+	"errors"
 )
 
+// ErrNotSupported is returned by optional APIs of KV wrappers,
+// e.g. [LayeredKV], if none of the wrapped storages supports it.
+// The decoder handles it as if the API is not implemented.
+var ErrNotSupported = errors.New("not supported")
+
 // KV is a key-value storage API.
 //
 // It provides a method to get a value by key.
@@ -11,6 +17,52 @@ type KV interface {
 	Get(ctx context.Context, key string) (Value, error)
 }
 
+// Lister is an optional key-value storage API to list keys.
+//
+// KV implementations could implement it to support decoding of
+// fields which keys are not known in advance, e.g. map fields.
+type Lister interface {
+	// List returns all keys which start with the prefix,
+	// including keys of nested levels.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// PrefixFetcher is an optional key-value storage API to fetch
+// all values under a prefix at once.
+//
+// If KV implements it and the decoder has a prefix, the decoder fetches
+// all values under the prefix in one request and decodes the struct from
+// the in-memory snapshot, instead of requesting each key separately.
+type PrefixFetcher interface {
+	// FetchPrefix returns all key-value pairs which keys start with the prefix,
+	// including keys of nested levels. Empty prefix means all keys.
+	FetchPrefix(ctx context.Context, prefix string) (map[string]Value, error)
+}
+
+// Watcher is an optional key-value storage API to watch changes.
+//
+// If KV implements it, [Decoder.Watch] decodes the struct again
+// on each change of keys under the decoder prefix.
+type Watcher interface {
+	// Watch returns a channel which receives a notification when any key
+	// under the prefix changes. Notifications don't carry the changes,
+	// they could be dropped if the previous one was not received yet.
+	//
+	// The channel is closed when ctx is done or watching fails,
+	// implementations should retry transient errors.
+	Watch(ctx context.Context, prefix string) (<-chan struct{}, error)
+}
+
+// KVWriter is a writable key-value storage API.
+//
+// It's used by [Encoder] to store encoded values.
+type KVWriter interface {
+	// Put stores a value by key.
+	Put(ctx context.Context, key string, value string) error
+	// Delete removes a key, it's not an error if the key doesn't exist.
+	Delete(ctx context.Context, key string) error
+}
+
 // Value is a kv-storage value, that can be unmarshaled to a target type.
 //
 // KV implementation can use provided Value implementation to unmarshal,
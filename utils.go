@@ -2,57 +2,206 @@ package marshaler
 
 import (
 	"encoding"
+	"encoding/json"
+	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 )
 
-func initField(f reflect.Value, t reflect.StructField) {
-	// If the field is a pointer and is nil, create a new instance
-	if t.Type.Kind() == reflect.Ptr && f.IsNil() {
-		f.Set(reflect.New(t.Type.Elem()))
+// tryUnmarshalers unmarshals the value using standard unmarshaling
+// interfaces implemented by out, in the following order:
+//   - encoding.TextUnmarshaler
+//   - encoding.BinaryUnmarshaler
+//   - json.Unmarshaler
+//
+// The json.Unmarshaler gets the value as is if it's a valid JSON,
+// or as a JSON string otherwise.
+//
+// It returns false if out doesn't implement any of them.
+func tryUnmarshalers(out any, val string) (bool, error) {
+	switch u := out.(type) {
+	case encoding.TextUnmarshaler:
+		if err := u.UnmarshalText([]byte(val)); err != nil {
+			return true, fmt.Errorf("unmarshal text: %w", err)
+		}
+	case encoding.BinaryUnmarshaler:
+		if err := u.UnmarshalBinary([]byte(val)); err != nil {
+			return true, fmt.Errorf("unmarshal binary: %w", err)
+		}
+	case json.Unmarshaler:
+		data := []byte(val)
+		if !json.Valid(data) {
+			var err error
+			if data, err = json.Marshal(val); err != nil {
+				return true, fmt.Errorf("quote json string: %w", err)
+			}
+		}
+		if err := u.UnmarshalJSON(data); err != nil {
+			return true, fmt.Errorf("unmarshal json: %w", err)
+		}
+	default:
+		return false, nil
 	}
+	return true, nil
 }
 
-func tryTextUnmarshaler(f reflect.Value, val string) (bool, error) {
-	// check if the field implements the TextUnmarshaler interface and use it
-	iface := f.Interface()
-	unmarshaler, ok := iface.(encoding.TextUnmarshaler)
-	if !ok {
-		return false, nil
+var valueIfaces = []reflect.Type{
+	reflect.TypeOf((*Scanner)(nil)).Elem(),
+	reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem(),
+	reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem(),
+	reflect.TypeOf((*json.Unmarshaler)(nil)).Elem(),
+}
+
+// isValueType checks if the type (or pointer to it) could unmarshal
+// a value by itself, e.g. it implements Scanner or TextUnmarshaler.
+func isValueType(t reflect.Type) bool {
+	if t.Kind() != reflect.Ptr {
+		t = reflect.PointerTo(t)
 	}
-	if err := unmarshaler.UnmarshalText([]byte(val)); err != nil {
-		return false, err
+	for _, iface := range valueIfaces {
+		if t.Implements(iface) {
+			return true
+		}
 	}
-	return true, nil
+	return false
+}
+
+// isStructType checks if the type (or the pointer element type) is a struct
+// which should be decoded as nested struct and not as a single value.
+//
+// Structs which could unmarshal a value by themselves (e.g. time.Time
+// or Scanner implementations) are not nested structs.
+func isStructType(t reflect.Type) bool {
+	if isValueType(t) {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+// childKeys returns sorted unique names of direct children of the prefix.
+func childKeys(keys []string, prefix, sep string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	children := make([]string, 0, len(keys))
+	for _, k := range keys {
+		rest, ok := strings.CutPrefix(k, prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		child, _, _ := strings.Cut(rest, sep)
+		if child == "" {
+			continue
+		}
+		if _, ok := seen[child]; ok {
+			continue
+		}
+		seen[child] = struct{}{}
+		children = append(children, child)
+	}
+	sort.Strings(children)
+	return children
+}
+
+// leafKeys filters keys which are direct children of the prefix.
+func leafKeys(keys []string, prefix, sep string) []string {
+	leafs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if !strings.Contains(strings.TrimPrefix(k, prefix), sep) {
+			leafs = append(leafs, k)
+		}
+	}
+	return leafs
 }
 
-func structField(f reflect.Value, t reflect.StructField) (reflect.Value, bool) {
-	_, isScanner := f.Interface().(Scanner)
-	if isScanner {
-		// scanner can scan value by itself even if it's a struct.
-		return f, false
+// isIndexedLayout checks if slice or array field is stored as indexed
+// keys (`key/0`, `key/1`, ...) rather than a single joined value.
+//
+// The layout could be set explicitly by `indexed` or `joined` tag options,
+// by default arrays and slices of structs are indexed.
+func isIndexedLayout(t reflect.Type, spec tagSpec) bool {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return false
+	}
+	switch {
+	case spec.indexed:
+		return true
+	case spec.joined:
+		return false
 	}
+	return t.Kind() == reflect.Array || isStructType(t.Elem())
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// isEmbeddedStruct checks if the field is an embedded struct,
+// fields of unexported embedded structs are still accessible,
+// but pointers to them can't be allocated.
+func isEmbeddedStruct(sf reflect.StructField) bool {
+	return sf.Anonymous && sf.Type.Kind() == reflect.Struct && isStructType(sf.Type)
+}
 
-	switch t.Type.Kind() {
-	case reflect.Ptr:
-		if e := f.Elem(); e.Kind() == reflect.Struct {
-			return e, true
+// fieldInterface returns the value of the field to format it.
+//
+// Pointers are dereferenced, but if the value implements formatting
+// interfaces only by pointer receiver, the pointer is returned.
+func fieldInterface(f reflect.Value) any {
+	if f.Kind() == reflect.Ptr {
+		f = f.Elem()
+	}
+	iface := f.Interface()
+	switch iface.(type) {
+	case encoding.TextMarshaler, encoding.BinaryMarshaler, json.Marshaler, fmt.Stringer:
+		return iface
+	}
+	if f.CanAddr() {
+		switch ptr := f.Addr().Interface(); ptr.(type) {
+		case encoding.TextMarshaler, encoding.BinaryMarshaler, json.Marshaler, fmt.Stringer:
+			return ptr
 		}
-	case reflect.Struct:
-		return f, true
 	}
-	return f, false
+	return iface
 }
 
 type tagSpec struct {
 	key       string
 	omitempty bool
+	required  bool
+	secret    bool
+	indexed   bool
+	joined    bool
+	inline    bool
+	sep       string
+
+	// def is a default value, it's used if hasDef is true
+	// and the key is missing.
+	def    string
+	hasDef bool
+
+	// usage is a description of the field, see BindFlags.
+	usage string
 }
 
+// defaultTag is a struct tag name for default values,
+// alternative to `default=` tag option.
+const defaultTag = "default"
+
+// usageTag is a struct tag name for field descriptions.
+const usageTag = "usage"
+
 func getTagSpec(tag string) tagSpec {
 	// tag could be
 	//  `kv:"myKey,omitempty"`
+	//  `kv:"myKey,required"`
+	//  `kv:"myKey,secret"`
 	//  `kv:"myKey"`
+	//  `kv:"myKey,indexed"`
+	//  `kv:"myKey,sep=;"`
+	//  `kv:",inline"` (or `kv:",squash"`)
+	//  `kv:"myKey,default=a,b"` (default is the last option)
 
 	specs := strings.Split(tag, ",")
 	if len(specs) == 0 {
@@ -60,11 +209,71 @@ func getTagSpec(tag string) tagSpec {
 	}
 	var spec tagSpec
 	spec.key = specs[0]
-	for _, s := range specs[1:] {
+	for i, s := range specs[1:] {
+		if def, ok := strings.CutPrefix(s, "default="); ok {
+			// default value could contain commas, so it takes
+			// the rest of the tag.
+			spec.def = strings.Join(append([]string{def}, specs[i+2:]...), ",")
+			spec.hasDef = true
+			break
+		}
 		switch s {
 		case "omitempty":
 			spec.omitempty = true
+		case "required":
+			spec.required = true
+		case "secret":
+			spec.secret = true
+		case "indexed":
+			spec.indexed = true
+		case "joined":
+			spec.joined = true
+		case "inline", "squash":
+			spec.inline = true
+		default:
+			if sep, ok := strings.CutPrefix(s, "sep="); ok {
+				spec.sep = sep
+			}
 		}
 	}
 	return spec
 }
+
+// fieldTagSpec returns the tag spec of the struct field,
+// or false if the field should be skipped.
+//
+// Fields without the tag (or with empty key in the tag) get the key
+// from the name strategy if it's set, otherwise untagged fields are
+// skipped. Fields with `-` tag are always skipped.
+//
+// Embedded structs without the key are inline, they have no key
+// and their fields are decoded from the key space of the parent.
+func fieldTagSpec(t reflect.StructField, tag string, names NameStrategy) (tagSpec, bool) {
+	val := t.Tag.Get(tag)
+	if val == "-" {
+		return tagSpec{}, false
+	}
+	spec := getTagSpec(val)
+	if t.Anonymous && spec.key == "" && isStructType(t.Type) {
+		spec.inline = true
+	}
+	if spec.inline {
+		spec.key = ""
+		return spec, true
+	}
+	if val == "" && names == nil {
+		return tagSpec{}, false
+	}
+	if spec.key == "" {
+		if names == nil {
+			names = ExactName
+		}
+		spec.key = names(t.Name)
+	}
+	if def, ok := t.Tag.Lookup(defaultTag); ok {
+		spec.def = def
+		spec.hasDef = true
+	}
+	spec.usage = t.Tag.Get(usageTag)
+	return spec, true
+}
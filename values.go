@@ -1,7 +1,10 @@
 package marshaler
 
 import (
+	"encoding"
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -29,7 +32,12 @@ func (nullValue) UnmarshalTo(out any, opts ValueUnmarshalOpts) error {
 // - time.Duration
 // - time.Time (RFC3339)
 // - []string
+// - slices and arrays of the types above, e.g. []int or []time.Duration
 // - Scanner
+// - encoding.TextUnmarshaler, encoding.BinaryUnmarshaler, json.Unmarshaler
+//
+// Scanner and standard unmarshaling interfaces are preferred
+// over builtin conversions, in the order listed above.
 type StringValue struct {
 	value string
 }
@@ -44,6 +52,11 @@ func NewBytesValue(value []byte) StringValue {
 	return StringValue{value: string(value)}
 }
 
+// String returns the raw string value.
+func (v StringValue) String() string {
+	return v.value
+}
+
 // UnmarshalTo unmarshals the string value to a target type using the provided options.
 func (v StringValue) UnmarshalTo(out any, opts ValueUnmarshalOpts) error {
 	if s, ok := out.(Scanner); ok {
@@ -52,6 +65,9 @@ func (v StringValue) UnmarshalTo(out any, opts ValueUnmarshalOpts) error {
 		}
 		return nil
 	}
+	if ok, err := tryUnmarshalers(out, v.value); ok {
+		return err
+	}
 
 	var parseErr error
 	switch out := out.(type) {
@@ -92,13 +108,13 @@ func (v StringValue) UnmarshalTo(out any, opts ValueUnmarshalOpts) error {
 		}
 		*out = uint(uintVal)
 	case *uint8:
-		parseErr = unmarshalIntNumber(v.value, 8, out)
+		parseErr = unmarshalUintNumber(v.value, 8, out)
 	case *uint16:
-		parseErr = unmarshalIntNumber(v.value, 16, out)
+		parseErr = unmarshalUintNumber(v.value, 16, out)
 	case *uint32:
-		parseErr = unmarshalIntNumber(v.value, 32, out)
+		parseErr = unmarshalUintNumber(v.value, 32, out)
 	case *uint64:
-		parseErr = unmarshalIntNumber(v.value, 64, out)
+		parseErr = unmarshalUintNumber(v.value, 64, out)
 	case *bool:
 		boolVal, err := strconv.ParseBool(v.value)
 		if err != nil {
@@ -110,15 +126,14 @@ func (v StringValue) UnmarshalTo(out any, opts ValueUnmarshalOpts) error {
 	case *float64:
 		parseErr = unmarshalFloatNumber(v.value, 64, out)
 
-	case *[]string:
-		if opts.SliceSep == "" {
-			return fmt.Errorf("slice separator is not set")
-		}
-		sep := opts.SliceSep
-		*out = strings.Split(v.value, sep)
-
 	default:
-		return fmt.Errorf("unsupported type %T", out)
+		if rv := reflect.ValueOf(out); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+			switch rv.Elem().Kind() {
+			case reflect.Slice, reflect.Array:
+				return v.unmarshalSlice(rv.Elem(), opts)
+			}
+		}
+		return fmt.Errorf("%w %T", ErrUnsupportedType, out)
 	}
 
 	if parseErr != nil {
@@ -128,8 +143,138 @@ func (v StringValue) UnmarshalTo(out any, opts ValueUnmarshalOpts) error {
 	return nil
 }
 
+// unmarshalSlice splits the value by slice separator and unmarshals
+// each part to the slice or array element.
+func (v StringValue) unmarshalSlice(out reflect.Value, opts ValueUnmarshalOpts) error {
+	if opts.SliceSep == "" {
+		return fmt.Errorf("slice separator is not set")
+	}
+	var parts []string
+	if v.value != "" {
+		parts = strings.Split(v.value, opts.SliceSep)
+	}
+
+	if out.Kind() == reflect.Array {
+		if len(parts) > out.Len() {
+			return fmt.Errorf("too many elements for %s: %d", out.Type(), len(parts))
+		}
+	} else {
+		out.Set(reflect.MakeSlice(out.Type(), len(parts), len(parts)))
+	}
+	for i, part := range parts {
+		elem := out.Index(i)
+		if err := NewStringValue(part).UnmarshalTo(elem.Addr().Interface(), opts); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// formatValue converts a value to a string, it's the inverse of
+// [StringValue.UnmarshalTo]: the result could be unmarshaled back
+// to the same type using the same options.
+//
+// Standard marshaling interfaces are preferred over builtin conversions,
+// the same way as unmarshaling interfaces in StringValue. Other types
+// (e.g. Scanner implementations) could be formatted if they implement
+// fmt.Stringer.
+func formatValue(in any, opts ValueUnmarshalOpts) (string, error) {
+	switch in := in.(type) {
+	case encoding.TextMarshaler:
+		text, err := in.MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("marshal text: %w", err)
+		}
+		return string(text), nil
+	case encoding.BinaryMarshaler:
+		data, err := in.MarshalBinary()
+		if err != nil {
+			return "", fmt.Errorf("marshal binary: %w", err)
+		}
+		return string(data), nil
+	case json.Marshaler:
+		data, err := in.MarshalJSON()
+		if err != nil {
+			return "", fmt.Errorf("marshal json: %w", err)
+		}
+		// unquote JSON strings, see tryUnmarshalers.
+		var str string
+		if json.Unmarshal(data, &str) == nil {
+			return str, nil
+		}
+		return string(data), nil
+	}
+
+	switch in := in.(type) {
+	case time.Duration:
+		return in.String(), nil
+	case time.Time:
+		return in.Format(time.RFC3339Nano), nil
+
+	case string:
+		return in, nil
+
+	case int:
+		return strconv.FormatInt(int64(in), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(in), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(in), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(in), 10), nil
+	case int64:
+		return strconv.FormatInt(in, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(in), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(in), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(in), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(in), 10), nil
+	case uint64:
+		return strconv.FormatUint(in, 10), nil
+	case bool:
+		return strconv.FormatBool(in), nil
+	case float32:
+		return strconv.FormatFloat(float64(in), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(in, 'g', -1, 64), nil
+
+	case []string:
+		if opts.SliceSep == "" {
+			return "", fmt.Errorf("slice separator is not set")
+		}
+		return strings.Join(in, opts.SliceSep), nil
+
+	case fmt.Stringer:
+		return in.String(), nil
+	}
+
+	if rv := reflect.ValueOf(in); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		if opts.SliceSep == "" {
+			return "", fmt.Errorf("slice separator is not set")
+		}
+		parts := make([]string, rv.Len())
+		for i := range parts {
+			part, err := formatValue(fieldInterface(rv.Index(i)), opts)
+			if err != nil {
+				return "", fmt.Errorf("element %d: %w", i, err)
+			}
+			parts[i] = part
+		}
+		return strings.Join(parts, opts.SliceSep), nil
+	}
+
+	return "", fmt.Errorf("%w %T", ErrUnsupportedType, in)
+}
+
 type intNumber interface {
-	int8 | int16 | int32 | int64 | uint8 | uint16 | uint32 | uint64
+	int8 | int16 | int32 | int64
+}
+
+type uintNumber interface {
+	uint8 | uint16 | uint32 | uint64
 }
 
 type floatNumber interface {
@@ -139,7 +284,16 @@ type floatNumber interface {
 func unmarshalIntNumber[T intNumber](s string, size int, out *T) error {
 	val, err := strconv.ParseInt(s, 10, size)
 	if err != nil {
-		return fmt.Errorf("parse (u)int%d from %q: %w", size, s, err)
+		return fmt.Errorf("parse int%d from %q: %w", size, s, err)
+	}
+	*out = T(val)
+	return nil
+}
+
+func unmarshalUintNumber[T uintNumber](s string, size int, out *T) error {
+	val, err := strconv.ParseUint(s, 10, size)
+	if err != nil {
+		return fmt.Errorf("parse uint%d from %q: %w", size, s, err)
 	}
 	*out = T(val)
 	return nil
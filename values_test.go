@@ -2,6 +2,10 @@ package marshaler
 
 import (
 	"errors"
+	"log/slog"
+	"math/big"
+	"net/netip"
+	"strings"
 	"testing"
 	"time"
 )
@@ -30,6 +34,24 @@ func (b *errScanner) Scan(v any) error {
 	return errors.New("scan error")
 }
 
+type binaryValue struct {
+	data string
+}
+
+func (b *binaryValue) UnmarshalBinary(data []byte) error {
+	b.data = string(data)
+	return nil
+}
+
+type jsonValue struct {
+	data string
+}
+
+func (j *jsonValue) UnmarshalJSON(data []byte) error {
+	j.data = string(data)
+	return nil
+}
+
 func TestNilValue(t *testing.T) {
 	var s string
 	if err := NullValue.UnmarshalTo(&s, ValueUnmarshalOpts{}); err != nil {
@@ -222,6 +244,15 @@ func TestStringValue(t *testing.T) {
 		if target[1] != "world" {
 			t.Fatalf("expected %q, got %q", "world", target[1])
 		}
+		t.Run("Empty", func(t *testing.T) {
+			target := []string{"old"}
+			if err := NewStringValue("").UnmarshalTo(&target, ValueUnmarshalOpts{SliceSep: ","}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(target) != 0 {
+				t.Fatalf("expected empty slice, got %q", target)
+			}
+		})
 		t.Run("NoSep", func(t *testing.T) {
 			var target []string
 			const value = "hello"
@@ -231,6 +262,145 @@ func TestStringValue(t *testing.T) {
 			}
 		})
 	})
+	t.Run("TypedSlice", func(t *testing.T) {
+		opts := ValueUnmarshalOpts{SliceSep: ","}
+		t.Run("Int", func(t *testing.T) {
+			var target []int
+			if err := NewStringValue("1,2,3").UnmarshalTo(&target, opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(target) != 3 || target[0] != 1 || target[2] != 3 {
+				t.Fatalf("expected [1 2 3], got %v", target)
+			}
+		})
+		t.Run("Bool", func(t *testing.T) {
+			var target []bool
+			if err := NewStringValue("true,false").UnmarshalTo(&target, opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(target) != 2 || !target[0] || target[1] {
+				t.Fatalf("expected [true false], got %v", target)
+			}
+		})
+		t.Run("Float64", func(t *testing.T) {
+			var target []float64
+			if err := NewStringValue("0.5;1.5").UnmarshalTo(&target, ValueUnmarshalOpts{SliceSep: ";"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(target) != 2 || target[0] != 0.5 || target[1] != 1.5 {
+				t.Fatalf("expected [0.5 1.5], got %v", target)
+			}
+		})
+		t.Run("Duration", func(t *testing.T) {
+			var target []time.Duration
+			if err := NewStringValue("1s,2m").UnmarshalTo(&target, opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(target) != 2 || target[0] != time.Second || target[1] != 2*time.Minute {
+				t.Fatalf("expected [1s 2m0s], got %v", target)
+			}
+		})
+		t.Run("Scanner", func(t *testing.T) {
+			var target []scanner
+			if err := NewStringValue("a,b").UnmarshalTo(&target, opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(target) != 2 || target[0].value != "a" || target[1].value != "b" {
+				t.Fatalf("expected [a b], got %v", target)
+			}
+		})
+		t.Run("Array", func(t *testing.T) {
+			var target [3]int
+			if err := NewStringValue("1,2").UnmarshalTo(&target, opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if target != [3]int{1, 2, 0} {
+				t.Fatalf("expected [1 2 0], got %v", target)
+			}
+			if err := NewStringValue("1,2,3,4").UnmarshalTo(&target, opts); err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+		})
+		t.Run("Empty", func(t *testing.T) {
+			var target []int
+			if err := NewStringValue("").UnmarshalTo(&target, opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(target) != 0 {
+				t.Fatalf("expected empty slice, got %v", target)
+			}
+		})
+		t.Run("InvalidElement", func(t *testing.T) {
+			var target []int
+			err := NewStringValue("1,x,3").UnmarshalTo(&target, opts)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), "element 1") {
+				t.Fatalf("expected element index in error, got %q", err)
+			}
+		})
+	})
+	t.Run("TextUnmarshaler", func(t *testing.T) {
+		t.Run("Addr", func(t *testing.T) {
+			var target netip.Addr
+			if err := NewStringValue("10.0.0.1").UnmarshalTo(&target, ValueUnmarshalOpts{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if target != netip.MustParseAddr("10.0.0.1") {
+				t.Fatalf("expected %v, got %v", "10.0.0.1", target)
+			}
+		})
+		t.Run("Level", func(t *testing.T) {
+			var target slog.Level
+			if err := NewStringValue("WARN").UnmarshalTo(&target, ValueUnmarshalOpts{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if target != slog.LevelWarn {
+				t.Fatalf("expected %v, got %v", slog.LevelWarn, target)
+			}
+		})
+		t.Run("BigInt", func(t *testing.T) {
+			var target big.Int
+			const value = "123456789012345678901234567890"
+			if err := NewStringValue(value).UnmarshalTo(&target, ValueUnmarshalOpts{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if target.String() != value {
+				t.Fatalf("expected %s, got %s", value, target.String())
+			}
+		})
+		t.Run("Invalid", func(t *testing.T) {
+			var target netip.Addr
+			if err := NewStringValue("invalid").UnmarshalTo(&target, ValueUnmarshalOpts{}); err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+		})
+	})
+	t.Run("BinaryUnmarshaler", func(t *testing.T) {
+		var target binaryValue
+		if err := NewStringValue("bin").UnmarshalTo(&target, ValueUnmarshalOpts{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if target.data != "bin" {
+			t.Fatalf("expected %q, got %q", "bin", target.data)
+		}
+	})
+	t.Run("JSONUnmarshaler", func(t *testing.T) {
+		var target jsonValue
+		if err := NewStringValue(`{"a":1}`).UnmarshalTo(&target, ValueUnmarshalOpts{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if target.data != `{"a":1}` {
+			t.Fatalf("expected %q, got %q", `{"a":1}`, target.data)
+		}
+		if err := NewStringValue("plain").UnmarshalTo(&target, ValueUnmarshalOpts{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if target.data != `"plain"` {
+			t.Fatalf("expected %q, got %q", `"plain"`, target.data)
+		}
+	})
 	t.Run("Invalid", func(t *testing.T) {
 		t.Run("Scanner", func(t *testing.T) {
 			var target errScanner
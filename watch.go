@@ -0,0 +1,91 @@
+package marshaler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Watch decodes a fresh value on each change of keys under the decoder
+// prefix and passes it to onChange along with the decode error, if any.
+// The key-value storage must implement [Watcher].
+//
+// The newFn returns a new decode target on each change, e.g.
+// `func() any { return new(Config) }`. Bursts of changes are coalesced,
+// see [WithDebounce]. The onChange is called sequentially from the
+// calling goroutine, changes made while it's running are coalesced
+// into the next call. The initial value is not delivered, it should be
+// decoded by [Decoder.DecodeContext] before watching.
+//
+// Watch blocks until ctx is done and returns nil then. It returns
+// an error if watching could not be started or the watch was stopped
+// by the storage.
+func (d *Decoder) Watch(ctx context.Context, newFn func() any, onChange func(v any, err error)) error {
+	if newFn == nil || onChange == nil {
+		return fmt.Errorf("nil watch function")
+	}
+	watcher, ok := d.kv.(Watcher)
+	if !ok {
+		return fmt.Errorf("%w: kv doesn't support watching", ErrBackend)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	changes, err := watcher.Watch(ctx, d.keys.prefix)
+	if err != nil {
+		return fmt.Errorf("%w: watch prefix %q: %w", ErrBackend, d.keys.prefix, err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-changes:
+			if !ok {
+				return d.watchClosed(ctx)
+			}
+		}
+		if !d.settle(ctx, changes) {
+			return d.watchClosed(ctx)
+		}
+		v := newFn()
+		err := d.DecodeContext(ctx, v)
+		if ctx.Err() != nil {
+			return nil
+		}
+		onChange(v, err)
+	}
+}
+
+// settle waits until there are no changes for the debounce period.
+// It returns false if ctx is done or the changes channel is closed.
+func (d *Decoder) settle(ctx context.Context, changes <-chan struct{}) bool {
+	if d.config.debounce == 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d.config.debounce)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case _, ok := <-changes:
+			if !ok {
+				return false
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(d.config.debounce)
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// watchClosed returns the result of Watch when the changes channel
+// is closed or ctx is done.
+func (d *Decoder) watchClosed(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return fmt.Errorf("%w: watch prefix %q: stopped by kv", ErrBackend, d.keys.prefix)
+}
@@ -0,0 +1,152 @@
+package marshaler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// watchKV is a MapKV which could notify watchers about changes.
+type watchKV struct {
+	mux     sync.Mutex
+	data    MapKV
+	changes chan struct{}
+}
+
+func newWatchKV(data MapKV) *watchKV {
+	return &watchKV{data: data, changes: make(chan struct{}, 1)}
+}
+
+func (w *watchKV) Get(ctx context.Context, key string) (Value, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.data.Get(ctx, key)
+}
+
+func (w *watchKV) Watch(ctx context.Context, prefix string) (<-chan struct{}, error) {
+	out := make(chan struct{})
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-w.changes:
+				if !ok {
+					return
+				}
+				out <- struct{}{}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (w *watchKV) Put(key, value string) {
+	w.mux.Lock()
+	w.data[key] = value
+	w.mux.Unlock()
+	w.changes <- struct{}{}
+}
+
+func TestWatch(t *testing.T) {
+	type config struct {
+		Port int `kv:"port"`
+	}
+	newConfig := func() any { return new(config) }
+
+	t.Run("Changes", func(t *testing.T) {
+		kv := newWatchKV(MapKV{"port": "80"})
+		dec, err := NewDecoder(kv, WithDebounce(50*time.Millisecond))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		updates := make(chan *config)
+		errs := make(chan error)
+		done := make(chan error, 1)
+		go func() {
+			done <- dec.Watch(ctx, newConfig, func(v any, err error) {
+				if err != nil {
+					errs <- err
+					return
+				}
+				updates <- v.(*config)
+			})
+		}()
+
+		// burst of changes is delivered as the last value.
+		for _, port := range []string{"81", "82", "83"} {
+			kv.Put("port", port)
+		}
+		if cfg := <-updates; cfg.Port != 83 {
+			t.Fatalf("expected port 83, got %d", cfg.Port)
+		}
+		kv.Put("port", "abc")
+		if err := <-errs; !errors.Is(err, ErrParse) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		kv.Put("port", "84")
+		if cfg := <-updates; cfg.Port != 84 {
+			t.Fatalf("expected port 84, got %d", cfg.Port)
+		}
+
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("watch was not stopped")
+		}
+	})
+	t.Run("Stopped", func(t *testing.T) {
+		kv := newWatchKV(MapKV{})
+		dec, err := NewDecoder(kv)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		close(kv.changes)
+		err = dec.Watch(context.Background(), newConfig, func(any, error) {})
+		if !errors.Is(err, ErrBackend) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		dec, err := NewDecoder(MapKV{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = dec.Watch(context.Background(), newConfig, func(any, error) {})
+		if !errors.Is(err, ErrBackend) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Layered", func(t *testing.T) {
+		kv := newWatchKV(MapKV{"port": "80"})
+		dec, err := NewDecoder(Layered(MapKV{}, kv), WithDebounce(0))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		updates := make(chan int, 1)
+		go dec.Watch(ctx, newConfig, func(v any, err error) {
+			if err == nil {
+				updates <- v.(*config).Port
+			}
+		})
+		kv.Put("port", "81")
+		select {
+		case port := <-updates:
+			if port != 81 {
+				t.Fatalf("expected port 81, got %d", port)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("change was not delivered")
+		}
+	})
+}